@@ -0,0 +1,144 @@
+// Package amount holds the fixed-point monetary Amount shared by the claimTransfer01 and consensus01 chaincodes and
+// the x12 package, so that every amount on the ledger is parsed and written the same way. An Amount is a whole number
+// of cents and is exchanged as a decimal string e.g. "1250.75", so no precision is lost through float conversion.
+package amount
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//==============================================================================================================================
+//	Amount - Fixed-point monetary value held as a whole number of cents.
+//==============================================================================================================================
+type Amount int64
+
+//==============================================================================================================================
+//	 UNDEFINED - The value records written before amounts were typed hold for an amount not yet set. It is read as zero.
+//==============================================================================================================================
+const UNDEFINED = "UNDEFINED"
+
+//==============================================================================================================================
+//	 MAX_UNITS - The largest whole number of units an Amount can hold without its cents overflowing.
+//==============================================================================================================================
+const MAX_UNITS = (math.MaxInt64 - 99) / 100
+
+//==============================================================================================================================
+//	 Parse - Converts a decimal string with at most two fractional digits into an Amount. An empty value and UNDEFINED
+//			 are read as zero. The whole part may be left out, as X12 writes ".5", but there must be at least one digit.
+//			 Signs other than a leading "-", exponents and anything else strconv.ParseFloat would accept are rejected.
+//==============================================================================================================================
+func Parse(value string) (Amount, error) {
+	return parse(value, false)
+}
+
+//==============================================================================================================================
+//	 ParseRounded - Converts a decimal string like Parse but rounds any digits past the second decimal place half away
+//					from zero. The digits are read directly so that amounts such as 1.005 are not misrounded as floats.
+//==============================================================================================================================
+func ParseRounded(value string) (Amount, error) {
+	return parse(value, true)
+}
+
+//==============================================================================================================================
+//	 parse - Parses an amount, rounding extra decimal places when round is set and rejecting them otherwise.
+//==============================================================================================================================
+func parse(value string, round bool) (Amount, error) {
+
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" || trimmed == UNDEFINED {
+		return 0, nil
+	}
+
+	invalid := errors.New("Invalid amount " + value)
+	digits := strings.TrimPrefix(trimmed, "-")
+	negative := len(digits) < len(trimmed)
+	whole, fraction := digits, ""
+	if i := strings.Index(digits, "."); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+	}
+	if whole == "" && fraction == "" {
+		return 0, invalid // A sign or decimal point on its own
+	}
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return 0, invalid
+		}
+	}
+	up := false
+	if len(fraction) > 2 {
+		if !round {
+			return 0, invalid
+		}
+		up = fraction[2] >= '5'
+		fraction = fraction[:2]
+	}
+	fraction = (fraction + "00")[:2]
+	if whole == "" {
+		whole = "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > MAX_UNITS {
+		return 0, invalid
+	}
+	cents := units*100 + int64(fraction[0]-'0')*10 + int64(fraction[1]-'0')
+	if up {
+		cents++ // MAX_UNITS leaves room for the cent
+	}
+	if negative {
+		cents = -cents
+	}
+	return Amount(cents), nil
+}
+
+//==============================================================================================================================
+//	 Add - Adds two amounts, failing rather than wrapping around when the sum does not fit in an Amount.
+//==============================================================================================================================
+func Add(a Amount, b Amount) (Amount, error) {
+
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, errors.New("Amount " + a.String() + " plus " + b.String() + " is too large")
+	}
+	return a + b, nil
+}
+
+//==============================================================================================================================
+//	 String - Renders the Amount as a decimal string with two fractional digits.
+//==============================================================================================================================
+func (a Amount) String() string {
+
+	sign := ""
+	v := uint64(a)
+	if a < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+//==============================================================================================================================
+//	 MarshalJSON / UnmarshalJSON - Amounts are written to the ledger as quoted decimal strings.
+//==============================================================================================================================
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return errors.New("Amount must be a quoted decimal string")
+	}
+	parsed, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
@@ -0,0 +1,150 @@
+package amount
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	cases := []struct {
+		value    string
+		expected Amount
+		valid    bool
+	}{
+		{"1250.75", 125075, true},
+		{"80", 8000, true},
+		{"150.5", 15050, true},
+		{".5", 50, true},
+		{"-.5", -50, true},
+		{"5.", 500, true},
+		{"-12.30", -1230, true},
+		{" 7.01 ", 701, true},
+		{"", 0, true},
+		{"UNDEFINED", 0, true},
+		{"92233720368547757.99", 9223372036854775799, true},
+		{"92233720368547758", 0, false},
+		{"999999999999999999999", 0, false},
+		{"-", 0, false},
+		{".", 0, false},
+		{"-.", 0, false},
+		{"1.234", 0, false},
+		{"1.2.3", 0, false},
+		{"+5", 0, false},
+		{"--5", 0, false},
+		{"1.-5", 0, false},
+		{"1e3", 0, false},
+		{"abc", 0, false},
+	}
+	for _, c := range cases {
+		a, err := Parse(c.value)
+		if c.valid && (err != nil || a != c.expected) {
+			t.Errorf("Parse(%q) = %d, %v, expected %d", c.value, a, err, c.expected)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("Parse(%q) = %d, expected an error", c.value, a)
+		}
+	}
+}
+
+func TestParseRounded(t *testing.T) {
+
+	cases := []struct {
+		value    string
+		expected Amount
+		valid    bool
+	}{
+		{"80.05", 8005, true},
+		{"1.005", 101, true},
+		{"1.004", 100, true},
+		{"0.995", 100, true},
+		{"-0.005", -1, true},
+		{"92233720368547757.995", 9223372036854775800, true},
+		{"92233720368547758", 0, false},
+		{"1.2.3", 0, false},
+		{"1.23x", 0, false},
+	}
+	for _, c := range cases {
+		a, err := ParseRounded(c.value)
+		if c.valid && (err != nil || a != c.expected) {
+			t.Errorf("ParseRounded(%q) = %d, %v, expected %d", c.value, a, err, c.expected)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("ParseRounded(%q) = %d, expected an error", c.value, a)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+
+	cases := map[Amount]string{
+		0:                    "0.00",
+		5:                    "0.05",
+		50:                   "0.50",
+		125075:               "1250.75",
+		-1230:                "-12.30",
+		-5:                   "-0.05",
+		1000000:              "10000.00",
+		math.MinInt64:        "-92233720368547758.08",
+		9223372036854775799:  "92233720368547757.99",
+		-9223372036854775799: "-92233720368547757.99",
+	}
+	for a, expected := range cases {
+		if a.String() != expected {
+			t.Errorf("Amount(%d).String() = %q, expected %q", int64(a), a.String(), expected)
+		}
+		if a == math.MinInt64 {
+			continue // Past MAX_UNITS so it can not be parsed back
+		}
+		parsed, err := Parse(expected)
+		if err != nil || parsed != a {
+			t.Errorf("Parse(%q) = %d, %v, expected %d", expected, parsed, err, int64(a))
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+
+	cases := []struct {
+		a, b     Amount
+		expected Amount
+		valid    bool
+	}{
+		{125075, 5050, 130125, true},
+		{math.MaxInt64 - 1, 1, math.MaxInt64, true},
+		{math.MaxInt64, 1, 0, false},
+		{math.MaxInt64 / 2, math.MaxInt64/2 + 2, 0, false},
+		{math.MinInt64 + 1, -1, math.MinInt64, true},
+		{math.MinInt64, -1, 0, false},
+	}
+	for _, c := range cases {
+		sum, err := Add(c.a, c.b)
+		if c.valid && (err != nil || sum != c.expected) {
+			t.Errorf("Add(%d, %d) = %d, %v, expected %d", c.a, c.b, sum, err, c.expected)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("Add(%d, %d) = %d, expected an error", c.a, c.b, sum)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+
+	var v struct {
+		Charged Amount `json:"charged"`
+	}
+	err := json.Unmarshal([]byte(`{"charged":"150.5"}`), &v)
+	if err != nil || v.Charged != 15050 {
+		t.Fatalf("Unmarshal = %d, %v, expected 15050", v.Charged, err)
+	}
+	bytes, err := json.Marshal(v)
+	if err != nil || string(bytes) != `{"charged":"150.50"}` {
+		t.Errorf("Marshal = %s, %v", bytes, err)
+	}
+	for _, data := range []string{`{"charged":150.5}`, `{"charged":"1.234"}`} {
+		if err := json.Unmarshal([]byte(data), &v); err == nil {
+			t.Errorf("Unmarshal(%s) expected an error", data)
+		}
+	}
+}
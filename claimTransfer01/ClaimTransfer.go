@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/amount"
+	"github.com/ibm-blockchain/example02/router"
 )
 
 var logger = shim.NewLogger("CLDChaincode")
@@ -19,6 +21,7 @@ const Initiator = "user_type1_0"
 const Host = "user_type2_0"
 const Home = "user_type8_0"
 const CFA = "user_type4_0"
+const Admin = "admin"
 
 //==============================================================================================================================
 //	 Status types - Claim Approval lifecycle is broken down into 5 statuses, this is part of the business logic to determine what can
@@ -29,6 +32,7 @@ const STATE_HOST = "1"
 const STATE_HOME = "2"
 const STATE_HOME_HOST = "3"
 const STATE_CFA = "4"
+const STATE_DENIED = "5"
 
 //==============================================================================================================================
 //	 Structure Definitions
@@ -44,85 +48,56 @@ type SimpleChaincode struct {
 //			  that element when reading a JSON object into the struct e.g. JSON make -> Struct Make.
 //==============================================================================================================================
 type Claim struct {
-	ClaimID        string `json:"claimId"`
-	ServiceDate    string `json:"serviceDate"`
-	AdmissionDate  string `json:"admissionDate"`
-	ProviderID     string `json:"providerId"`
-	MemberID       string `json:"memberId"`
-	SubscriberID   string `json:"subscriberId"`
-	DiagCode       string `json:"diagCode"`
-	ProcedureCode  string `json:"procedureCode"`
-	ProcedureDate  string `json:"procedureDate"`
-	BillCode       string `json:"billCode"`
-	SrvcUnitNbr    string `json:"SrvcUnitNbr"`
-	RevenueCode    string `json:"revenueCode"`
-	RevenueDesc    string `json:"revenueDesc"`
-	AdmsnHourCode  string `json:"admsnHourCode"`
-	AdmsnTypeCode  string `json:"admsnTypeCode"`
-	AdmsnSrvcCode  string `json:"admsnSrvcCode"`
-	UnitOfService  string `json:"unitOfService"`
-	ChargedAmount  string `json:"chargedAmount"`
-	NonCovAmount   string `json:"nonCovAmount"`
-	ApprovedAmount string `json:"approvedAmount"`
-	LocalPlanCode  string `json:"localPlanCode"`
-	RemotePlanCode string `json:"remotePlanCode"`
-	CostShare      string `json:"costShare"`
-	AdjustmentFlag string `json:"adjustmentFlag"`
-	Owner          string `json:"owner"`
-	FinalAmount    string `json:"finalApprovedAmount"`
-	PaymentMethod  string `json:"paymentMethod"`
-	ClaimStatus    string `json:"claimStatus"`
+	ClaimID         string            `json:"claimId"`
+	ServiceDate     string            `json:"serviceDate"`
+	AdmissionDate   string            `json:"admissionDate"`
+	ProviderID      string            `json:"providerId"`
+	MemberID        string            `json:"memberId"`
+	SubscriberID    string            `json:"subscriberId"`
+	DiagCode        string            `json:"diagCode"`
+	ProcedureCode   string            `json:"procedureCode"`
+	ProcedureDate   string            `json:"procedureDate"`
+	BillCode        string            `json:"billCode"`
+	SrvcUnitNbr     string            `json:"SrvcUnitNbr"`
+	RevenueCode     string            `json:"revenueCode"`
+	RevenueDesc     string            `json:"revenueDesc"`
+	AdmsnHourCode   string            `json:"admsnHourCode"`
+	AdmsnTypeCode   string            `json:"admsnTypeCode"`
+	AdmsnSrvcCode   string            `json:"admsnSrvcCode"`
+	UnitOfService   string            `json:"unitOfService"`
+	ChargedAmount   amount.Amount     `json:"chargedAmount"`
+	NonCovAmount    amount.Amount     `json:"nonCovAmount"`
+	ApprovedAmount  amount.Amount     `json:"approvedAmount"`
+	LocalPlanCode   string            `json:"localPlanCode"`
+	RemotePlanCode  string            `json:"remotePlanCode"`
+	CostShare       amount.Amount     `json:"costShare"`
+	AdjustmentFlag  string            `json:"adjustmentFlag"`
+	Owner           string            `json:"owner"`
+	FinalAmount     amount.Amount     `json:"finalApprovedAmount"`
+	PaymentMethod   string            `json:"paymentMethod"`
+	ClaimStatus     string            `json:"claimStatus"`
+	PaymentRef      string            `json:"paymentReference"`
+	StatusReason    string            `json:"statusReason"`
+	StatusNote      string            `json:"statusNote"`
+	AppealCount     int               `json:"appealCount"`
+	OriginalClaimID string            `json:"originalClaimId"`
+	AdjustmentType  string            `json:"adjustmentType"`
+	AdjustmentIDs   []string          `json:"adjustmentIds"`
+	States          []StateEntry      `json:"states"`
+	DuplicateStatus string            `json:"duplicateStatus"`
+	DuplicateOf     []string          `json:"duplicateOf"`
+	Lines           []ClaimLine       `json:"lines"`
+	PrivateSalt     string            `json:"privateSalt,omitempty"`
+	PrivateHashes   map[string]string `json:"privateHashes,omitempty"`
 }
 
 //==============================================================================================================================
-//	 Init - Initialize the process by creating one record in system validating owner and then storing the information
+//	 Init - Called when the chaincode is deployed. Claims are checked against the plans, providers and members registered
+//			on the ledger, none of which exist yet, so deploying creates no claim. Claims are created with the
+//			create_claim invoke.
 //==============================================================================================================================
 func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	var A, B, C, D, E, F, G string // Entities
-	///var names = []string{"user_type1_0"} //username
 
-	var err error
-	var callerName string
-
-	if len(args) != 20 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 20")
-	}
-
-	//get caller name
-	//callerName, err = t.get_username(stub)
-	//if callerName != "user_type1_0" { // Only the Provider can create a new claim
-
-	//	return nil, fmt.Errorf("Permission Denied. User is not authorized to create record%s==%s", callerName, Initiator)
-
-	//}
-	//if err != nil {
-	//	return nil, fmt.Errorf("Not got the user details from back end")
-	//}
-	// Initialize the chaincode
-	A = args[0]
-	B = args[1]
-	C = args[2]
-	D = args[3]
-	E = args[4]
-	F = args[5]
-	G = args[6]
-	H := args[7]
-	I := args[8]
-	J := args[9]
-	K := args[10]
-	L := args[11]
-	M := args[12]
-	N := args[13]
-	O := args[14]
-	P := args[15]
-	Q := args[16]
-	R := args[17]
-	S := args[18]
-	T := args[19]
-	_, err = t.create_claim(stub, callerName, A, B, C, D, E, F, G, H, I, J, K, L, M, N, O, P, Q, R, S, T)
-	if err != nil {
-		return nil, fmt.Errorf("Not able to create claim")
-	}
 	return nil, nil
 }
 
@@ -155,48 +130,125 @@ func (t *SimpleChaincode) get_username(stub shim.ChaincodeStubInterface) (string
 	return string(username), nil
 }
 
+//==============================================================================================================================
+//	 verify_caller - Returns an error unless the caller named in the arguments is the user whose ecert signed the
+//					 transaction. The functions check the caller they are passed, so it must not be taken on trust.
+//==============================================================================================================================
+func (t *SimpleChaincode) verify_caller(stub shim.ChaincodeStubInterface, caller string) error {
+
+	user, err := t.get_username(stub)
+	if err != nil {
+		return err
+	}
+	if caller == "" || user != caller {
+		return errors.New("Permission Denied. The transaction was not signed by " + caller)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 ROLE_ATTRIBUTE - The ecert attribute granting the Admin role. The Admin is named as the caller like any other user
+//					  but is only accepted from a transaction signed with an ecert carrying the attribute.
+//==============================================================================================================================
+const ROLE_ATTRIBUTE = "role"
+
+//==============================================================================================================================
+//	 verify_role - Returns an error unless the ecert of the user who invoked the chaincode grants the role passed in.
+//==============================================================================================================================
+func (t *SimpleChaincode) verify_role(stub shim.ChaincodeStubInterface, role string) error {
+
+	ok, err := stub.VerifyAttribute(ROLE_ATTRIBUTE, []byte(role))
+	if err != nil {
+		return errors.New("Couldn't verify attribute '" + ROLE_ATTRIBUTE + "'. Error: " + err.Error())
+	}
+	if !ok {
+		return errors.New("Permission Denied. The caller's certificate does not grant the " + role + " role")
+	}
+	return nil
+}
+
 //=================================================================================================================================
 //	 Create Function
 //=================================================================================================================================
 //	 Create Vehicle - Creates the initial JSON for the vehcile and then saves it to the ledger.
 //=================================================================================================================================
-func (t *SimpleChaincode) create_claim(stub shim.ChaincodeStubInterface, caller string, arg0 string, arg1 string, arg2 string, arg3 string, arg4 string, arg5 string, arg6 string, arg7 string, arg8 string, arg9 string, arg10 string, arg11 string, arg12 string, arg13 string, arg14 string, arg15 string, arg16 string, arg17 string, arg18 string, arg19 string) ([]byte, error) {
+func (t *SimpleChaincode) create_claim(stub shim.ChaincodeStubInterface, caller string, plan string, arg0 string, arg1 string, arg2 string, arg3 string, arg4 string, arg5 string, arg6 string, arg7 string, arg8 string, arg9 string, arg10 string, arg11 string, arg12 string, arg13 string, arg14 string, arg15 string, arg16 string, arg17 string, arg18 string, arg19 string) ([]byte, error) {
 	var c Claim
 	var err error
-	claimID := "\"claimId\":\"" + arg0 + "\", "
-	ServiceDate := "\"serviceDate\":\"" + arg1 + "\", "
-	AdmissionDate := "\"admissionDate\":\"" + arg2 + "\", "
-	ProviderID := "\"providerId\":\"" + arg3 + "\", "
-	MemberID := "\"memberId\":\"" + arg4 + "\", "
-	SubscriberID := "\"subscriberId\":\"" + arg5 + "\", "
-	DiagCode := "\"diagCode\":\"" + arg6 + "\", "
-	ProcedureCode := "\"procedureCode\":\"" + arg7 + "\", "
-	ProcedureDate := "\"procedureDate\":\"" + arg8 + "\", "
-	BillCode := "\"billCode\":\"" + arg9 + "\", "
-	SrvcUnitNbr := "\"SrvcUnitNbr\":\"" + arg10 + "\", "
-	RevenueCode := "\"revenueCode\":\"" + arg11 + "\", "
-	RevenueDesc := "\"revenueDesc\":\"" + arg12 + "\", "
-	AdmsnHourCode := "\"admsnHourCode\":\"" + arg13 + "\", "
-	AdmsnTypeCode := "\"admsnTypeCode\":\"" + arg14 + "\", "
-	AdmsnSrvcCode := "\"admsnSrvcCode\":\"" + arg15 + "\", "
-	UnitOfService := "\"unitOfService\":\"" + arg16 + "\", "
-	ChargedAmount := "\"chargedAmount\":\"" + arg17 + "\", "
-	NonCovAmount := "\"nonCovAmount\":\"" + arg18 + "\", "
-	ApprovedAmount := "\"approvedAmount\":\"UNDEFINED\", "
-	LocalPlanCode := "\"localPlanCode\":\"UNDEFINED\", "
-	RemotePlanCode := "\"remotePlanCode\":\"UNDEFINED\", "
-	CostShare := "\"costShare\":\"UNDEFINED\", "
-	AdjustmentFlag := "\"adjustmentFlag\":\"UNDEFINED\", "
-	Owner := "\"owner\":\"" + arg19 + "\" ,"
-	FinalAmount := "\"finalApprovedAmount\":\"UNDEFINED\", "
-	PaymentMethod := "\"paymentMethod\":\"UNDEFINED\", "
-	ClaimStatus := "\"claimStatus\":\"INITIATED\" "
-	claim_json := "{" + claimID + ServiceDate + AdmissionDate + ProviderID + MemberID + SubscriberID + DiagCode + ProcedureCode + ProcedureDate + BillCode + SrvcUnitNbr + RevenueCode + RevenueDesc + AdmsnHourCode + AdmsnTypeCode + AdmsnSrvcCode + UnitOfService + ChargedAmount + NonCovAmount + ApprovedAmount + LocalPlanCode + RemotePlanCode + CostShare + AdjustmentFlag + Owner + FinalAmount + PaymentMethod + ClaimStatus + "}" // Concatenates the variables to create the total JSON object
-
-	err = json.Unmarshal([]byte(claim_json), &c) // Convert the JSON defined above into a Claim object for go
-
-	if err != nil {
-		return nil, errors.New("Invalid JSON object")
+
+	if caller != Initiator {
+		return nil, errors.New("Permission Denied. Only an Initiator can create claims")
+	}
+	charged, err := parse_charged_amount(arg17)
+	if err != nil {
+		return nil, errors.New("Invalid charged amount: " + err.Error())
+	}
+	nonCovered, err := amount.Parse(arg18)
+	if err != nil {
+		return nil, errors.New("Invalid non covered amount: " + err.Error())
+	}
+	err = t.validate_code(stub, CODE_DIAGNOSIS, arg6)
+	if err != nil {
+		return nil, err
+	}
+	err = t.validate_service_codes(stub, arg7, arg11, arg9)
+	if err != nil {
+		return nil, err
+	}
+	coverage, err := t.eligible_coverage(stub, arg4, arg5, arg1) // The member must be covered on the service date
+	if err != nil {
+		return nil, err
+	}
+	provider, err := t.active_provider(stub, arg3, arg1)
+	if err != nil {
+		return nil, err
+	}
+
+	c = Claim{ // Each argument only ever sets its own field
+		ClaimID:        arg0,
+		ServiceDate:    arg1,
+		AdmissionDate:  arg2,
+		ProviderID:     arg3,
+		MemberID:       arg4,
+		SubscriberID:   arg5,
+		DiagCode:       arg6,
+		ProcedureCode:  arg7,
+		ProcedureDate:  arg8,
+		BillCode:       arg9,
+		SrvcUnitNbr:    arg10,
+		RevenueCode:    arg11,
+		RevenueDesc:    arg12,
+		AdmsnHourCode:  arg13,
+		AdmsnTypeCode:  arg14,
+		AdmsnSrvcCode:  arg15,
+		UnitOfService:  arg16,
+		ChargedAmount:  charged,
+		NonCovAmount:   nonCovered,
+		LocalPlanCode:  "UNDEFINED",
+		RemotePlanCode: "UNDEFINED",
+		AdjustmentFlag: "UNDEFINED",
+		Owner:          arg19,
+		PaymentMethod:  "UNDEFINED",
+		ClaimStatus:    STATUS_INITIATED,
+	}
+	if c.ClaimID == "" {
+		return nil, errors.New("A claim id is required")
+	}
+
+	line, err := new_claim_line(1, arg7, arg11, arg9, arg16, arg17, arg18) // The claim level service details become the first line
+	if err != nil {
+		return nil, err
+	}
+	c.Lines = []ClaimLine{line}
+	c.LocalPlanCode = provider.HomePlan  // Claims are routed to the Host plan the provider belongs to
+	c.RemotePlanCode = coverage.PlanCode // and the Home plan covering the member
+	if plan != c.LocalPlanCode {
+		return nil, errors.New("Permission Denied. " + arg19 + " does not act for plan " + c.LocalPlanCode)
+	}
+
+	err = t.check_routing(stub, c)
+	if err != nil {
+		return nil, err
 	}
 
 	record, err := stub.GetState(c.ClaimID)
@@ -206,19 +258,46 @@ func (t *SimpleChaincode) create_claim(stub shim.ChaincodeStubInterface, caller
 		return nil, errors.New("Claim already exists")
 	}
 
+	err = validate_amounts(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.check_duplicate(stub, &c)
+	if err != nil {
+		return nil, err
+	}
+	err = t.index_duplicate(stub, c) // The duplicate keys need the member and procedure before they are sealed
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.enter_state(stub, &c, STATE_INITIATE)
+	if err != nil {
+		return nil, err
+	}
+
 	_, err = t.save_changes(stub, c)
 
 	if err != nil {
 		fmt.Printf("CREATE_CLAIM: Error saving changes: %s", err)
 		return nil, errors.New("Error saving changes")
 	}
-	bytes, err := stub.GetState(c.ClaimID)
+	c, err = t.retrieve_claim(stub, c.ClaimID) // The history records the claim as stored, with its PHI sealed
 	if err != nil {
-		return nil, errors.New("Error in retriving information")
+		return nil, err
 	}
-	err = stub.PutState("State", []byte(STATE_INITIATE))
+	err = t.record_history(stub, "create_claim", c.Owner, Claim{}, c)
 	if err != nil {
-		return nil, errors.New("Error in putting information")
+		return nil, err
+	}
+	err = t.index_claim(stub, c.ClaimID)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := stub.GetState(c.ClaimID)
+	if err != nil {
+		return nil, errors.New("Error in retriving information")
 	}
 
 	stringVal := "[" + arg0 + "]"
@@ -236,6 +315,12 @@ func (t *SimpleChaincode) create_claim(stub shim.ChaincodeStubInterface, caller
 //==============================================================================================================================
 func (t *SimpleChaincode) save_changes(stub shim.ChaincodeStubInterface, c Claim) (bool, error) {
 
+	err := t.seal_private(stub, &c) // PHI is only written to its private collections
+	if err != nil {
+		logger.Errorf("SAVE_CHANGES: Error sealing private fields: %s", err)
+		return false, err
+	}
+
 	bytes, err := json.Marshal(c)
 
 	if err != nil {
@@ -261,143 +346,344 @@ func (t *SimpleChaincode) save_changes(stub shim.ChaincodeStubInterface, c Claim
 //==============================================================================================================================
 func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 
-	var claimId string //get input from front end
-	var err error
-	var c Claim // claim object
-	claimId = args[1]
+	return router.Dispatch(stub, "Invoke", t.invoke_routes(), function, args)
 
-	bytes, err := stub.GetState(claimId)
+}
 
-	if err != nil {
-		return nil, errors.New("The claim id is not available in back end")
-	}
-	err = json.Unmarshal(bytes, &c)
-	if err != nil {
-		return nil, errors.New("Unmarshalling failed for claim")
-	}
-	storedUser := c.Owner
-	if function == "transfer_to_host" {
-		return t.transfer_to_host(stub, claimId, c, args[0], storedUser)
-	} else if function == "update_by_host" {
-		return t.update_by_host(stub, claimId, c, args[0], args[2], args[3], args[4], storedUser)
-	} else if function == "transfer_to_home" {
-		return t.transfer_to_home(stub, claimId, c, args[0], storedUser)
-	} else if function == "update_by_home" {
-		return t.update_by_home(stub, claimId, c, args[0], args[2], args[3], storedUser)
-	} else if function == "transfer_to_hostByHome" {
-		return t.transfer_to_hostByHome(stub, claimId, c, args[0], storedUser)
-	} else if function == "update_by_hostForCFA" {
-		return t.update_by_hostForCFA(stub, claimId, c, args[0], args[2], args[3], storedUser)
-	} else if function == "transfer_to_cfa" {
-		return t.transfer_to_cfa(stub, claimId, c, args[0], storedUser)
-	} else if function == "Init" {
+//==============================================================================================================================
+//	claim_handler - An Invoke function acting on one claim. It is passed the stored claim, the caller's role on it and
+//					the full argument list.
+//==============================================================================================================================
+type claim_handler func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error)
 
-		return t.Init(stub, function, args)
-	}
-	return nil, nil
+//==============================================================================================================================
+//	 invoke_routes - The functions Invoke can call. Configuration functions do not act on a claim, the others take the
+//					 caller and ClaimID as their first two arguments.
+//==============================================================================================================================
+func (t *SimpleChaincode) invoke_routes() map[string]router.Route {
 
+	create := router.Exactly(20, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+		caller, plan, err := t.caller_role(stub, args[19]) // The owner in args[19] creates the claim
+		if err != nil {
+			return nil, err
+		}
+		return t.create_claim(stub, caller, plan, args[0], args[1], args[2], args[3], args[4], args[5], args[6], args[7], args[8], args[9], args[10], args[11], args[12], args[13], args[14], args[15], args[16], args[17], args[18], args[19])
+	})
+
+	return map[string]router.Route{
+		"create_claim": create,
+		"Init":         create, // Clients written before create_claim created claims by invoking Init
+		"set_sla": router.Exactly(3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.set_sla(stub, caller, args[1], args[2])
+		}),
+		"register_plan": router.Exactly(4, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.register_plan(stub, caller, args[1], args[2], args[3])
+		}),
+		"register_participant": router.Exactly(4, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.register_participant(stub, caller, args[1], args[2], args[3])
+		}),
+		"register_collection_key": router.Exactly(3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.register_collection_key(stub, caller, args[1], args[2])
+		}),
+		"load_codes": router.Exactly(3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.load_codes(stub, caller, args[1], args[2])
+		}),
+		"set_format_only": router.Exactly(3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.set_format_only(stub, caller, args[1], args[2])
+		}),
+		"register_member": router.Exactly(3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.register_member(stub, caller, args[1], args[2])
+		}),
+		"add_coverage": router.Exactly(5, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, plan, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.add_coverage(stub, caller, plan, args[1], args[2], args[3], args[4])
+		}),
+		"submit_fhir_claim": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, plan, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.submit_fhir_claim(stub, caller, plan, args[0], args[1])
+		}),
+		"register_provider": router.Exactly(7, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.register_provider(stub, caller, args[1], args[2], args[3], args[4], args[5], args[6])
+		}),
+
+		"transfer_to_host": t.claim_route("transfer_to_host", 2, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.transfer_to_host(stub, claimId, c, caller, storedUser)
+		}),
+		"update_by_host": t.claim_route("update_by_host", 5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.update_by_host(stub, claimId, c, caller, args[2], args[3], args[4], storedUser)
+		}),
+		"transfer_to_home": t.claim_route("transfer_to_home", 2, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.transfer_to_home(stub, claimId, c, caller, storedUser)
+		}),
+		"update_by_home": t.claim_route("update_by_home", 4, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.update_by_home(stub, claimId, c, caller, args[2], args[3], storedUser)
+		}),
+		"transfer_to_hostByHome": t.claim_route("transfer_to_hostByHome", 2, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.transfer_to_hostByHome(stub, claimId, c, caller, storedUser)
+		}),
+		"update_by_hostForCFA": t.claim_route("update_by_hostForCFA", 5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.update_by_hostForCFA(stub, claimId, c, caller, args[2], args[3], args[4], storedUser)
+		}),
+		"transfer_to_cfa": t.claim_route("transfer_to_cfa", 2, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.transfer_to_cfa(stub, claimId, c, caller, storedUser)
+		}),
+		"add_claim_line": t.claim_route("add_claim_line", 8, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.add_claim_line(stub, claimId, c, caller, args[2], args[3], args[4], args[5], args[6], args[7])
+		}),
+		"update_claim_line": t.claim_route("update_claim_line", 9, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.update_claim_line(stub, claimId, c, caller, args[2], args[3], args[4], args[5], args[6], args[7], args[8])
+		}),
+		"approve_claim_line": t.claim_route("approve_claim_line", 5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.approve_claim_line(stub, claimId, c, caller, args[2], args[3], args[4])
+		}),
+		"deny_claim": t.claim_route("deny_claim", 4, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.deny_claim(stub, claimId, c, caller, args[2], args[3], storedUser)
+		}),
+		"return_to_initiator": t.claim_route("return_to_initiator", 3, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.return_to_initiator(stub, claimId, c, caller, args[2], storedUser)
+		}),
+		"resubmit_claim": t.claim_route("resubmit_claim", 2, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.resubmit_claim(stub, claimId, c, caller, storedUser)
+		}),
+		"appeal_claim": t.claim_route("appeal_claim", 3, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.appeal_claim(stub, claimId, c, caller, args[2], storedUser)
+		}),
+		"adjust_claim": t.claim_route("adjust_claim", 7, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.adjust_claim(stub, claimId, c, caller, args[2], args[3], args[4], args[5], args[6], storedUser)
+		}),
+		"void_claim": t.claim_route("void_claim", 5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string, args []string) ([]byte, error) {
+			return t.void_claim(stub, claimId, c, caller, args[2], args[3], args[4], storedUser)
+		}),
+	}
 }
 
-//=================================================================================================================================
-//	Query - Called on chaincode query. Takes a function name passed and calls that function. Passes the
-//  		initial arguments passed are passed on to the called function.
-//=================================================================================================================================
-func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	var c Claim
-	//var byteReturn []byte
+//==============================================================================================================================
+//	 claim_route - Returns a Route for an Invoke function acting on the claim in args[1]. The claim is loaded, the
+//				   function checked against the claim's status and the caller resolved to its role on the claim before
+//				   the handler runs. Every change the handler makes is added to the claim's audit history.
+//==============================================================================================================================
+func (t *SimpleChaincode) claim_route(function string, n int, handler claim_handler) router.Route {
 
-	stateBytes, errs := stub.GetState("State")
-	if errs != nil {
-		return nil, fmt.Errorf("Error with State")
-	}
-	if function == "get_claim_id" {
-		claimInfo, errors := stub.GetState("ClaimID")
-		if errors != nil {
-			return nil, fmt.Errorf("Error with Claim")
-		}
-		return claimInfo, nil
-	} else if function == "get_claim_details" {
+	return router.Exactly(n, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 
-		//if err == nil {
-		//	return bytes, nil
-		//}
-		fmt.Printf("Starting function get_claim_details")
+		var c Claim // claim object
+		claimId := args[1]
+
+		bytes, err := stub.GetState(claimId)
 
-		if len(args) != 2 {
-			return nil, errors.New("Argument number is not correct")
-		}
-		caller := args[0]
-		claimID := args[1]
-		bytes, err := stub.GetState(claimID)
 		if err != nil {
-			return nil, errors.New("not received state details")
+			return nil, errors.New("The claim id is not available in back end")
+		}
+		if bytes == nil {
+			return nil, errors.New("Claim " + claimId + " does not exist")
 		}
-
 		err = json.Unmarshal(bytes, &c)
-
 		if err != nil {
-			return nil, fmt.Errorf("Nort able to unmarshall the status")
+			return nil, errors.New("Unmarshalling failed for claim")
 		}
-		byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+		storedUser := c.Owner
+		caller, err := t.claim_role(stub, args[0], c) // The caller must act for one of the plans the claim is routed to
 		if err != nil {
-			return nil, fmt.Errorf("Error with getClaimDetails")
+			return nil, err
 		}
-		err = json.Unmarshal(byteReturn, &c)
-		outPut := c.ClaimID
-		fmt.Printf("The value is: %s", outPut)
-		return byteReturn, nil
-
-	} else if function == "allow_to_update" {
-		fmt.Printf("Starting function allow_to_update")
-
-		if len(args) != 2 {
-			return nil, errors.New("Argument number is not correct")
-		}
-		caller := args[0]
-		claimID := args[1]
-		bytes, err := stub.GetState(claimID)
+		err = check_transition(function, c, caller)
 		if err != nil {
-			return nil, errors.New("not received state details")
+			return nil, err
 		}
 
-		err = json.Unmarshal(bytes, &c)
+		result, err := handler(stub, claimId, c, caller, storedUser, args)
+		if err != nil {
+			return nil, err
+		}
 
+		err = t.record_invoke_history(stub, function, args[0], claimId, c) // Every change to the claim is added to its audit history
 		if err != nil {
-			return nil, fmt.Errorf("Nort able to unmarshall the status")
+			return nil, err
 		}
-		currentState := string(stateBytes)
-		if caller == Initiator && currentState == STATE_INITIATE {
-			byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+		return result, nil
+	})
+}
+
+//=================================================================================================================================
+//	Query - Called on chaincode query. Takes a function name passed and calls that function. Passes the
+//  		initial arguments passed are passed on to the called function.
+//=================================================================================================================================
+func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+
+	return router.Dispatch(stub, "Query", t.query_routes(), function, args)
+
+}
+
+//=================================================================================================================================
+//	 query_routes - The functions Query can call.
+//=================================================================================================================================
+func (t *SimpleChaincode) query_routes() map[string]router.Route {
+
+	return map[string]router.Route{
+		"get_claim_id": router.Between(0, 1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			claimInfo, errors := stub.GetState("ClaimID")
+			if errors != nil {
+				return nil, fmt.Errorf("Error with Claim")
+			}
+			return claimInfo, nil
+		}),
+		"get_claim_details": router.Exactly(2, t.query_claim_details),
+		"get_claim_history": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			c, err := t.retrieve_claim(stub, args[1])
 			if err != nil {
-				return nil, fmt.Errorf("Error with getClaimDetails")
+				return nil, err
 			}
-			return byteReturn, nil
-		} else if caller == Host && currentState == STATE_HOST {
-			byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+			caller, err := t.claim_role(stub, args[0], c)
 			if err != nil {
-				return nil, fmt.Errorf("Error with getClaimDetails")
+				return nil, err
 			}
-			return byteReturn, nil
-			//return stateBytes, nil
-		} else if caller == Host && currentState == STATE_HOME_HOST {
-			byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+			return t.get_claim_history(stub, args[1], caller)
+		}),
+		"get_claim_fhir": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			c, err := t.retrieve_claim(stub, args[1])
 			if err != nil {
-				return nil, fmt.Errorf("Error with getClaimDetails")
+				return nil, err
 			}
-			return byteReturn, nil
-		} else if caller == Home && currentState == STATE_HOME {
-			byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+			caller, err := t.claim_role(stub, args[0], c)
 			if err != nil {
-				return nil, fmt.Errorf("Error with getClaimDetails")
+				return nil, err
 			}
-			return byteReturn, nil
-		} else if caller == CFA && currentState == STATE_CFA {
-			byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+			return t.get_claim_fhir(stub, c, caller)
+		}),
+		"get_payment": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, plan, err := t.caller_role(stub, args[0])
 			if err != nil {
-				return nil, fmt.Errorf("Error with getClaimDetails")
+				return nil, err
 			}
-			return byteReturn, nil
-		}
+			return t.get_payment(stub, args[1], caller, plan)
+		}),
+		"reconcile_plans": router.Exactly(3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, plan, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.reconcile_plans(stub, args[1], args[2], caller, plan)
+		}),
+		"get_settlement_summary": router.Exactly(1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, plan, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.get_settlement_summary(stub, caller, plan)
+		}),
+		"get_code_set": router.Exactly(1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			return t.get_code_set(stub, args[0])
+		}),
+		"get_member": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.get_member(stub, caller, args[1])
+		}),
+		"check_eligibility": router.Exactly(4, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, _, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.check_eligibility(stub, caller, args[1], args[2], args[3])
+		}),
+		"get_provider": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			return t.get_provider(stub, args[1])
+		}),
+		"get_plans": router.Between(0, 1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			return t.get_plans(stub)
+		}),
+		"get_participant": router.Exactly(1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			return t.get_participant(stub, args[0])
+		}),
+		"get_sla": router.Between(0, 1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			return t.get_sla(stub)
+		}),
+		"overdue_claims": router.Between(1, 2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			asOf := ""
+			if len(args) == 2 {
+				asOf = args[1]
+			}
+			caller, plan, err := t.caller_role(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.overdue_claims(stub, caller, plan, asOf)
+		}),
+		"allow_to_update": router.Exactly(2, t.query_allow_to_update),
+	}
+}
+
+//=================================================================================================================================
+//	 query_claim_details - Returns the claim in args[1] as the caller in args[0] is allowed to see it.
+//=================================================================================================================================
+func (t *SimpleChaincode) query_claim_details(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	c, err := t.retrieve_claim(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+	caller, err := t.claim_role(stub, args[0], c)
+	if err != nil {
+		return nil, err
+	}
+	return t.get_claim_details(stub, c.ClaimID, c, caller)
+}
+
+//=================================================================================================================================
+//	 query_allow_to_update - Returns the claim in args[1] if the caller in args[0] is the party it is waiting on.
+//=================================================================================================================================
+func (t *SimpleChaincode) query_allow_to_update(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	c, err := t.retrieve_claim(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+	caller, err := t.claim_role(stub, args[0], c)
+	if err != nil {
+		return nil, err
+	}
+	if caller == stage_owner(c) {
+		return t.get_claim_details(stub, c.ClaimID, c, caller)
 	}
 	return nil, nil //all done
 }
@@ -414,13 +700,13 @@ func (t *SimpleChaincode) transfer_to_host(stub shim.ChaincodeStubInterface, cla
 	}
 	c.Owner = caller
 
-	_, err := t.save_changes(stub, c) // Write new state
-
+	err := t.enter_state(stub, &c, STATE_HOST)
 	if err != nil {
-		return nil, errors.New("Not able to save state")
+		return nil, err
 	}
-	errs := stub.PutState("State", []byte(STATE_HOST))
-	if errs != nil {
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
 		return nil, errors.New("Not able to save state")
 	}
 	return nil, nil // We are Done
@@ -439,13 +725,13 @@ func (t *SimpleChaincode) transfer_to_home(stub shim.ChaincodeStubInterface, cla
 	}
 	c.Owner = caller
 
-	_, err := t.save_changes(stub, c) // Write new state
-
+	err := t.enter_state(stub, &c, STATE_HOME)
 	if err != nil {
-		return nil, errors.New("Not able to save state")
+		return nil, err
 	}
-	errs := stub.PutState("State", []byte(STATE_HOME))
-	if errs != nil {
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
 		return nil, errors.New("Not able to save state")
 	}
 	return nil, nil // We are Done
@@ -464,13 +750,13 @@ func (t *SimpleChaincode) transfer_to_hostByHome(stub shim.ChaincodeStubInterfac
 	}
 	c.Owner = caller
 
-	_, err := t.save_changes(stub, c) // Write new state
-
+	err := t.enter_state(stub, &c, STATE_HOME_HOST)
 	if err != nil {
-		return nil, errors.New("Not able to save state")
+		return nil, err
 	}
-	errs := stub.PutState("State", []byte(STATE_HOME_HOST))
-	if errs != nil {
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
 		return nil, errors.New("Not able to save state")
 	}
 	return nil, nil // We are Done
@@ -489,13 +775,13 @@ func (t *SimpleChaincode) transfer_to_cfa(stub shim.ChaincodeStubInterface, clai
 	}
 	c.Owner = caller
 
-	_, err := t.save_changes(stub, c) // Write new state
-
+	err := t.enter_state(stub, &c, STATE_CFA)
 	if err != nil {
-		return nil, errors.New("Not able to save state")
+		return nil, err
 	}
-	errs := stub.PutState("State", []byte(STATE_CFA))
-	if errs != nil {
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
 		return nil, errors.New("Not able to save state")
 	}
 	return nil, nil // We are Done
@@ -515,11 +801,41 @@ func (t *SimpleChaincode) update_by_host(stub shim.ChaincodeStubInterface, claim
 	if user != Host {
 		return nil, errors.New("Owner is not matching")
 	}
-	c.ApprovedAmount = approvedAmt
-	c.LocalPlanCode = localPlan
-	c.RemotePlanCode = remotePlan
-	c.ClaimStatus = "HOSTAPPROVED"
-	_, err := t.save_changes(stub, c) // Write new state
+	approved, err := amount.Parse(approvedAmt)
+	if err != nil {
+		return nil, errors.New("Invalid approved amount: " + err.Error())
+	}
+	if len(c.Lines) == 1 {
+		c.Lines[0].ApprovedAmount = approved // A single line claim can be approved at claim level
+	}
+	c.ApprovedAmount = approved
+	c.FinalAmount = c.ApprovedAmount - c.CostShare
+	err = derive_totals(&c)
+	if err != nil {
+		return nil, err
+	}
+	if c.ApprovedAmount != approved {
+		return nil, fmt.Errorf("Approved amount %s does not match the sum of the approved line amounts %s", approved, c.ApprovedAmount)
+	}
+	if localPlan != "" && localPlan != c.LocalPlanCode && c.LocalPlanCode != "UNDEFINED" {
+		return nil, errors.New("Local plan code " + c.LocalPlanCode + " is set from the provider registry")
+	}
+	if localPlan != "" {
+		c.LocalPlanCode = localPlan
+	}
+	if remotePlan != "" && remotePlan != c.RemotePlanCode && c.RemotePlanCode != "UNDEFINED" {
+		return nil, errors.New("Remote plan code " + c.RemotePlanCode + " is set from the member's coverage")
+	}
+	if remotePlan != "" {
+		c.RemotePlanCode = remotePlan
+	}
+	c.ClaimStatus = STATUS_HOST_APPROVED
+
+	err = validate_amounts(c)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
 
 	if err != nil {
 		return nil, errors.New("Not able to save state")
@@ -541,10 +857,20 @@ func (t *SimpleChaincode) update_by_home(stub shim.ChaincodeStubInterface, claim
 	if user != Host {
 		return nil, errors.New("Owner is not matching")
 	}
-	c.CostShare = costShare
+	share, err := amount.Parse(costShare)
+	if err != nil {
+		return nil, errors.New("Invalid cost share: " + err.Error())
+	}
+	c.CostShare = share
+	c.FinalAmount = c.ApprovedAmount - c.CostShare
 	c.AdjustmentFlag = adjustmentFlag
-	c.ClaimStatus = "ADJUDICATED"
-	_, err := t.save_changes(stub, c) // Write new state
+	c.ClaimStatus = STATUS_ADJUDICATED
+
+	err = validate_amounts(c)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
 
 	if err != nil {
 		return nil, errors.New("Not able to save state")
@@ -559,17 +885,34 @@ func (t *SimpleChaincode) update_by_home(stub shim.ChaincodeStubInterface, claim
 //=================================================================================================================================
 //	 update_by_hostForCFA
 //=================================================================================================================================
-func (t *SimpleChaincode) update_by_hostForCFA(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, finalAmount string, paymentMethod string, storedUser string) ([]byte, error) {
+func (t *SimpleChaincode) update_by_hostForCFA(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, finalAmount string, paymentMethod string, referenceNumber string, storedUser string) ([]byte, error) {
 
 	user := caller
 	fmt.Printf("The Owner is: %s", user)
 	if user != Host {
 		return nil, errors.New("Owner is not matching")
 	}
-	c.FinalAmount = finalAmount
+	final, err := amount.Parse(finalAmount)
+	if err != nil {
+		return nil, errors.New("Invalid final amount: " + err.Error())
+	}
+	if c.PaymentRef != "" && c.PaymentRef != referenceNumber {
+		return nil, errors.New("Claim has already been paid under reference " + c.PaymentRef)
+	}
+	c.FinalAmount = final
 	c.PaymentMethod = paymentMethod
-	c.ClaimStatus = "PAYMENTCOMPLETE"
-	_, err := t.save_changes(stub, c) // Write new state
+	c.PaymentRef = referenceNumber
+	c.ClaimStatus = STATUS_PAYMENT_COMPLETE
+
+	err = validate_amounts(c) // Final amount must agree with the adjudicated approved amount and cost share
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.create_payment(stub, c, referenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
 
 	if err != nil {
 		return nil, errors.New("Not able to save state")
@@ -589,10 +932,14 @@ func (t *SimpleChaincode) get_claim_details(stub shim.ChaincodeStubInterface, cl
 	user := caller
 	fmt.Printf("The Owner is: %s", user)
 
-	bytes, err := json.Marshal(c)
+	err := t.reveal_private(stub, &c, user)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := project_claim(c, user) // Only return the fields the caller is entitled to see
 
 	if err != nil {
-		return nil, errors.New("Not able to save state")
+		return nil, err
 	}
 
 	return bytes, nil // We are Done
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	 Adjustment types - Set on Claim.AdjustmentType for claims created by adjust_claim and void_claim.
+//==============================================================================================================================
+const ADJUSTMENT_ADJUST = "ADJUSTMENT"
+const ADJUSTMENT_VOID = "VOID"
+
+const STATUS_VOIDED = "VOIDED"
+
+//==============================================================================================================================
+//	 retrieve_claim - Gets a Claim from the ledger. Returns an error if the claim does not exist.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_claim(stub shim.ChaincodeStubInterface, claimId string) (Claim, error) {
+
+	var c Claim
+
+	bytes, err := stub.GetState(claimId)
+	if err != nil {
+		return c, errors.New("Error retrieving claim " + claimId)
+	}
+	if bytes == nil {
+		return c, errors.New("Claim " + claimId + " does not exist")
+	}
+	err = json.Unmarshal(bytes, &c)
+	if err != nil {
+		return c, errors.New("Corrupt Claim record " + claimId)
+	}
+	return c, nil
+}
+
+//==============================================================================================================================
+//	 net_claim - Returns a copy of a paid claim with the signed deltas of all its adjustment claims added to the
+//				 approved, cost share and final amounts.
+//==============================================================================================================================
+func (t *SimpleChaincode) net_claim(stub shim.ChaincodeStubInterface, c Claim) (Claim, error) {
+
+	net := c
+	for _, id := range c.AdjustmentIDs {
+		adj, err := t.retrieve_claim(stub, id)
+		if err != nil {
+			return net, err
+		}
+		net.ApprovedAmount += adj.ApprovedAmount
+		net.CostShare += adj.CostShare
+		net.FinalAmount += adj.FinalAmount
+	}
+	return net, nil
+}
+
+//==============================================================================================================================
+//	 create_adjustment - Writes a new adjustment claim linked to the original, settles its delta as a Payment and adds
+//						 it to the original claim's list of adjustments.
+//==============================================================================================================================
+func (t *SimpleChaincode) create_adjustment(stub shim.ChaincodeStubInterface, function string, caller string, c *Claim, adjustmentId string, adjustmentType string, approvedDelta amount.Amount, costShareDelta amount.Amount, reason string, referenceNumber string) error {
+
+	if c.OriginalClaimID != "" {
+		return errors.New("Claim " + c.ClaimID + " is an adjustment, adjust the original claim " + c.OriginalClaimID)
+	}
+	if adjustmentId == "" || adjustmentId == c.ClaimID {
+		return errors.New("A new claim id is required for the adjustment")
+	}
+	record, err := stub.GetState(adjustmentId)
+	if err != nil {
+		return errors.New("Error retrieving claim " + adjustmentId)
+	}
+	if record != nil {
+		return errors.New("Claim already exists")
+	}
+
+	net, err := t.net_claim(stub, *c)
+	if err != nil {
+		return err
+	}
+	net.ApprovedAmount += approvedDelta
+	net.CostShare += costShareDelta
+	net.FinalAmount += approvedDelta - costShareDelta
+	err = validate_amounts(net) // The claim after all its adjustments must still satisfy the adjudication rules
+	if err != nil {
+		return err
+	}
+
+	adj := *c
+	adj.ClaimID = adjustmentId
+	adj.OriginalClaimID = c.ClaimID
+	adj.AdjustmentType = adjustmentType
+	adj.AdjustmentIDs = nil
+	adj.Lines = nil
+	adj.ChargedAmount = 0
+	adj.NonCovAmount = 0
+	adj.ApprovedAmount = approvedDelta
+	adj.CostShare = costShareDelta
+	adj.FinalAmount = approvedDelta - costShareDelta
+	adj.AdjustmentFlag = "Y"
+	adj.PaymentRef = referenceNumber
+	adj.StatusReason = reason
+	adj.StatusNote = ""
+	adj.AppealCount = 0
+	adj.ClaimStatus = STATUS_PAYMENT_COMPLETE
+	adj.States = nil
+	adj.DuplicateStatus = ""
+	adj.DuplicateOf = nil
+	err = t.copy_private(stub, *c, &adj)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.create_payment(stub, adj, referenceNumber)
+	if err != nil {
+		return err
+	}
+	_, err = t.save_changes(stub, adj)
+	if err != nil {
+		return errors.New("Not able to save state")
+	}
+	err = t.record_history(stub, function, caller, Claim{}, adj)
+	if err != nil {
+		return err
+	}
+	err = t.index_claim(stub, adjustmentId)
+	if err != nil {
+		return err
+	}
+
+	c.AdjustmentIDs = append(c.AdjustmentIDs, adjustmentId)
+	return nil
+}
+
+//=================================================================================================================================
+//	 Adjustment Functions
+//=================================================================================================================================
+//	 adjust_claim - The Host corrects a paid claim. A new claim carrying the signed change to the approved amount and
+//					cost share is linked to the original and the change in the final amount is settled as a payment.
+//=================================================================================================================================
+func (t *SimpleChaincode) adjust_claim(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, adjustmentId string, approvedDelta string, costShareDelta string, reason string, referenceNumber string, storedUser string) ([]byte, error) {
+
+	if caller != Host {
+		return nil, errors.New("Only the Host can adjust a claim")
+	}
+	approved, err := amount.Parse(approvedDelta)
+	if err != nil {
+		return nil, errors.New("Invalid approved amount change: " + err.Error())
+	}
+	share, err := amount.Parse(costShareDelta)
+	if err != nil {
+		return nil, errors.New("Invalid cost share change: " + err.Error())
+	}
+	if approved == 0 && share == 0 {
+		return nil, errors.New("An adjustment must change the approved amount or cost share")
+	}
+
+	err = t.create_adjustment(stub, "adjust_claim", caller, &c, adjustmentId, ADJUSTMENT_ADJUST, approved, share, reason, referenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+
+	return []byte(adjustmentId), nil // We are Done
+
+}
+
+//=================================================================================================================================
+//	 void_claim - The Host reverses a paid claim. The void claim carries the negative of everything paid on the original
+//				  and its adjustments, and the original is marked VOIDED so it can not be adjusted again.
+//=================================================================================================================================
+func (t *SimpleChaincode) void_claim(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, adjustmentId string, reason string, referenceNumber string, storedUser string) ([]byte, error) {
+
+	if caller != Host {
+		return nil, errors.New("Only the Host can void a claim")
+	}
+
+	net, err := t.net_claim(stub, c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.create_adjustment(stub, "void_claim", caller, &c, adjustmentId, ADJUSTMENT_VOID, -net.ApprovedAmount, -net.CostShare, reason, referenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	c.ClaimStatus = STATUS_VOIDED
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+
+	return []byte(adjustmentId), nil // We are Done
+
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	 parse_charged_amount - Converts a charged amount, which unlike other amounts must be given and greater than zero.
+//==============================================================================================================================
+func parse_charged_amount(value string) (amount.Amount, error) {
+
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" || trimmed == amount.UNDEFINED {
+		return 0, errors.New("A charged amount is required")
+	}
+	a, err := amount.Parse(trimmed)
+	if err != nil {
+		return 0, err
+	}
+	if a <= 0 {
+		return 0, errors.New("Charged amount " + value + " must be greater than zero")
+	}
+	return a, nil
+}
+
+//==============================================================================================================================
+//	 validate_amounts - Checks the adjudication invariants on a Claim before it is written to the ledger:
+//						ChargedAmount and NonCovAmount are not negative and NonCovAmount <= ChargedAmount,
+//						0 <= ApprovedAmount <= ChargedAmount - NonCovAmount,
+//						0 <= CostShare <= ApprovedAmount and FinalAmount = ApprovedAmount - CostShare.
+//==============================================================================================================================
+func validate_amounts(c Claim) error {
+
+	if c.ChargedAmount < 0 || c.NonCovAmount < 0 {
+		return errors.New("Charged and non covered amounts must not be negative")
+	}
+	if c.NonCovAmount > c.ChargedAmount {
+		return fmt.Errorf("Non covered amount %s exceeds charged amount %s", c.NonCovAmount, c.ChargedAmount)
+	}
+	if c.ApprovedAmount < 0 {
+		return errors.New("Approved amount must not be negative")
+	}
+	if c.ApprovedAmount > c.ChargedAmount-c.NonCovAmount {
+		return fmt.Errorf("Approved amount %s exceeds covered amount %s", c.ApprovedAmount, c.ChargedAmount-c.NonCovAmount)
+	}
+	if c.CostShare < 0 || c.CostShare > c.ApprovedAmount {
+		return fmt.Errorf("Cost share %s must be between 0.00 and approved amount %s", c.CostShare, c.ApprovedAmount)
+	}
+	if c.FinalAmount != c.ApprovedAmount-c.CostShare {
+		return fmt.Errorf("Final amount %s does not equal approved amount %s less cost share %s", c.FinalAmount, c.ApprovedAmount, c.CostShare)
+	}
+	return nil
+}
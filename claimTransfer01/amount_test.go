@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseChargedAmount(t *testing.T) {
+
+	for _, value := range []string{"", " ", "UNDEFINED", "0", "0.00", "-1.00", "x"} {
+		if _, err := parse_charged_amount(value); err == nil {
+			t.Errorf("parse_charged_amount(%q) expected an error", value)
+		}
+	}
+	if a, err := parse_charged_amount("0.01"); err != nil || a != 1 {
+		t.Errorf("parse_charged_amount(\"0.01\") = %d, %v", a, err)
+	}
+}
+
+func TestValidateAmounts(t *testing.T) {
+
+	adjudicated := Claim{ChargedAmount: 10000, NonCovAmount: 1000, ApprovedAmount: 8000, CostShare: 2000, FinalAmount: 6000}
+
+	cases := []struct {
+		name   string
+		change func(c *Claim)
+		valid  bool
+	}{
+		{"adjudicated", func(c *Claim) {}, true},
+		{"nothing approved", func(c *Claim) { c.ApprovedAmount, c.CostShare, c.FinalAmount = 0, 0, 0 }, true},
+		{"all covered approved", func(c *Claim) { c.ApprovedAmount, c.FinalAmount = 9000, 7000 }, true},
+		{"negative charge", func(c *Claim) { c.ChargedAmount = -1 }, false},
+		{"negative non covered", func(c *Claim) { c.NonCovAmount = -1 }, false},
+		{"non covered over charge", func(c *Claim) { c.NonCovAmount = 10001 }, false},
+		{"negative approved", func(c *Claim) { c.ApprovedAmount, c.CostShare, c.FinalAmount = -100, 0, -100 }, false},
+		{"approved over covered", func(c *Claim) { c.ApprovedAmount, c.FinalAmount = 9001, 7001 }, false},
+		{"negative cost share", func(c *Claim) { c.CostShare, c.FinalAmount = -1, 8001 }, false},
+		{"cost share over approved", func(c *Claim) { c.CostShare, c.FinalAmount = 8001, -1 }, false},
+		{"final not approved less cost share", func(c *Claim) { c.FinalAmount = 5999 }, false},
+	}
+	for _, tc := range cases {
+		c := adjudicated
+		tc.change(&c)
+		err := validate_amounts(c)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	FieldChange - A single field of the Claim JSON whose value was changed by a transaction.
+//==============================================================================================================================
+type FieldChange struct {
+	Field    string `json:"field"`
+	OldValue string `json:"oldValue"`
+	NewValue string `json:"newValue"`
+}
+
+//==============================================================================================================================
+//	AuditEntry - Records one mutation of a Claim. Entries are only ever appended to a claim's history, never changed.
+//==============================================================================================================================
+type AuditEntry struct {
+	Function  string        `json:"function"`
+	Caller    string        `json:"caller"`
+	TxID      string        `json:"txId"`
+	Timestamp string        `json:"timestamp"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+//==============================================================================================================================
+//	Claim_History - Holds the audit entries for one claim, stored under "History_" + ClaimID.
+//==============================================================================================================================
+type Claim_History struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+//==============================================================================================================================
+//	 history_key - Ledger key holding the history of a claim.
+//==============================================================================================================================
+func history_key(claimId string) string {
+	return "History_" + claimId
+}
+
+//==============================================================================================================================
+//	 tx_time - Returns the timestamp of the current transaction in UTC. Every peer sees the same value so it is safe
+//			   to store on the ledger, unlike the local clock.
+//==============================================================================================================================
+func tx_time(stub shim.ChaincodeStubInterface) (time.Time, error) {
+
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New("Couldn't get transaction timestamp. Error: " + err.Error())
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+//==============================================================================================================================
+//	 tx_timestamp - Returns the timestamp of the current transaction in RFC3339 format.
+//==============================================================================================================================
+func tx_timestamp(stub shim.ChaincodeStubInterface) (string, error) {
+
+	ts, err := tx_time(stub)
+	if err != nil {
+		return "", err
+	}
+	return ts.Format(time.RFC3339), nil
+}
+
+//==============================================================================================================================
+//	 diff_claims - Compares the JSON form of two Claims field by field. Fields are returned in name order so every
+//				   peer produces the same entry.
+//==============================================================================================================================
+func diff_claims(before Claim, after Claim) ([]FieldChange, error) {
+
+	var oldFields, newFields map[string]json.RawMessage
+
+	bytes, err := json.Marshal(before)
+	if err == nil {
+		err = json.Unmarshal(bytes, &oldFields)
+	}
+	if err != nil {
+		return nil, errors.New("Error converting Claim record for history")
+	}
+	bytes, err = json.Marshal(after)
+	if err == nil {
+		err = json.Unmarshal(bytes, &newFields)
+	}
+	if err != nil {
+		return nil, errors.New("Error converting Claim record for history")
+	}
+
+	names := make([]string, 0, len(newFields))
+	for name := range newFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		oldValue := string(oldFields[name])
+		newValue := string(newFields[name])
+		if oldValue != newValue {
+			changes = append(changes, FieldChange{Field: name, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	return changes, nil
+}
+
+//==============================================================================================================================
+//	 record_history - Appends an audit entry for the change from before to after to the claim's history.
+//==============================================================================================================================
+func (t *SimpleChaincode) record_history(stub shim.ChaincodeStubInterface, function string, caller string, before Claim, after Claim) error {
+
+	changes, err := diff_claims(before, after)
+	if err != nil {
+		return err
+	}
+	timestamp, err := tx_timestamp(stub)
+	if err != nil {
+		return err
+	}
+
+	history, err := t.retrieve_history(stub, after.ClaimID)
+	if err != nil {
+		return err
+	}
+	history.Entries = append(history.Entries, AuditEntry{
+		Function:  function,
+		Caller:    caller,
+		TxID:      stub.GetTxID(),
+		Timestamp: timestamp,
+		Changes:   changes,
+	})
+
+	bytes, err := json.Marshal(history)
+	if err != nil {
+		return errors.New("Error converting Claim_History record")
+	}
+	err = stub.PutState(history_key(after.ClaimID), bytes)
+	if err != nil {
+		logger.Errorf("RECORD_HISTORY: Error storing Claim_History record: %s", err)
+		return errors.New("Error storing Claim_History record")
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 record_invoke_history - Reads the claim back after an invoke has been applied and records the change against the
+//							 copy of the claim loaded before the invoke ran.
+//==============================================================================================================================
+func (t *SimpleChaincode) record_invoke_history(stub shim.ChaincodeStubInterface, function string, caller string, claimId string, before Claim) error {
+
+	var after Claim
+
+	bytes, err := stub.GetState(claimId)
+	if err != nil || bytes == nil {
+		return errors.New("Error retrieving claim " + claimId + " for history")
+	}
+	err = json.Unmarshal(bytes, &after)
+	if err != nil {
+		return errors.New("Corrupt Claim record " + claimId)
+	}
+	return t.record_history(stub, function, caller, before, after)
+}
+
+//==============================================================================================================================
+//	 retrieve_history - Gets the history of a claim from the ledger. A claim with no recorded history returns an
+//						empty Claim_History.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_history(stub shim.ChaincodeStubInterface, claimId string) (Claim_History, error) {
+
+	var history Claim_History
+
+	bytes, err := stub.GetState(history_key(claimId))
+	if err != nil {
+		return history, errors.New("Unable to get history for claim " + claimId)
+	}
+	if bytes == nil {
+		return history, nil
+	}
+	err = json.Unmarshal(bytes, &history)
+	if err != nil {
+		return history, errors.New("Corrupt Claim_History record for claim " + claimId)
+	}
+	return history, nil
+}
+
+//=================================================================================================================================
+//	 get_claim_history - Returns every audit entry recorded against a claim, oldest first. Changes to fields the caller
+//						 may not see are left out.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_claim_history(stub shim.ChaincodeStubInterface, claimId string, caller string) ([]byte, error) {
+
+	history, err := t.retrieve_history(stub, claimId)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := project_history(history.Entries, caller)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+
+	bytes, err := json.Marshal(entries)
+	if err != nil {
+		return nil, errors.New("Error converting Claim_History record")
+	}
+	return bytes, nil
+}
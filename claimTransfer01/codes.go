@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Code set types - Each code set has a format every code must match and a table of valid codes loaded onto the
+//					  ledger by the Admin. A code must be present in the table, so no code of a type is accepted until
+//					  its table is loaded, unless the Admin has set the type to be checked on format only.
+//==============================================================================================================================
+const CODE_DIAGNOSIS = "DIAGNOSIS"
+const CODE_PROCEDURE = "PROCEDURE"
+const CODE_REVENUE = "REVENUE"
+const CODE_BILL_TYPE = "BILLTYPE"
+
+var codeFormats = map[string]*regexp.Regexp{
+	CODE_DIAGNOSIS: regexp.MustCompile(`^[A-Z][0-9][0-9A-Z]([0-9A-Z]{1,4})?$`), // ICD-10-CM, held without the dot
+	CODE_PROCEDURE: regexp.MustCompile(`^([0-9]{4}[0-9FTU]|[A-V][0-9]{4})$`),   // CPT or HCPCS Level II
+	CODE_REVENUE:   regexp.MustCompile(`^[0-9]{4}$`),                           // UB-04 revenue code
+	CODE_BILL_TYPE: regexp.MustCompile(`^0?[0-9]{3}$`),                         // UB-04 type of bill
+}
+
+//==============================================================================================================================
+//	Code_Set - The valid codes of one type with their descriptions. Stored on the ledger under "CodeSet_" + type.
+//			   FormatOnly accepts any well formed code of the type that is not in the table.
+//==============================================================================================================================
+type Code_Set struct {
+	Codes      map[string]string `json:"codes"`
+	FormatOnly bool              `json:"formatOnly,omitempty"`
+}
+
+//==============================================================================================================================
+//	 normalise_code - Codes are compared in upper case with any ICD-10 dot removed.
+//==============================================================================================================================
+func normalise_code(code string) string {
+	return strings.ToUpper(strings.Replace(strings.TrimSpace(code), ".", "", -1))
+}
+
+//==============================================================================================================================
+//	 retrieve_code_set - Gets a code table from the ledger. A table that has never been loaded is returned empty.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_code_set(stub shim.ChaincodeStubInterface, codeType string) (Code_Set, error) {
+
+	var set Code_Set
+
+	if _, ok := codeFormats[codeType]; !ok {
+		return set, errors.New("Unknown code set " + codeType)
+	}
+	bytes, err := stub.GetState("CodeSet_" + codeType)
+	if err != nil {
+		return set, errors.New("Unable to get code set " + codeType)
+	}
+	if bytes != nil {
+		err = json.Unmarshal(bytes, &set)
+		if err != nil {
+			return set, errors.New("Corrupt Code_Set record " + codeType)
+		}
+	}
+	if set.Codes == nil {
+		set.Codes = make(map[string]string)
+	}
+	return set, nil
+}
+
+//==============================================================================================================================
+//	 check_code - Checks a code is well formed for its type and present in its table, unless the table is format only.
+//==============================================================================================================================
+func check_code(set Code_Set, codeType string, code string) error {
+
+	normal := normalise_code(code)
+	if !codeFormats[codeType].MatchString(normal) {
+		return errors.New("Invalid " + strings.ToLower(codeType) + " code " + code)
+	}
+	if _, ok := set.Codes[normal]; ok || set.FormatOnly {
+		return nil
+	}
+	if len(set.Codes) == 0 {
+		return errors.New("The " + strings.ToLower(codeType) + " code set has not been loaded")
+	}
+	return errors.New("Unknown " + strings.ToLower(codeType) + " code " + code)
+}
+
+//==============================================================================================================================
+//	 validate_code - Checks a code against the code set of its type on the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) validate_code(stub shim.ChaincodeStubInterface, codeType string, code string) error {
+
+	set, err := t.retrieve_code_set(stub, codeType)
+	if err != nil {
+		return err
+	}
+	return check_code(set, codeType, code)
+}
+
+//==============================================================================================================================
+//	 save_code_set - Writes a code table to the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) save_code_set(stub shim.ChaincodeStubInterface, codeType string, set Code_Set) error {
+
+	bytes, err := json.Marshal(set)
+	if err != nil {
+		return errors.New("Error converting Code_Set record")
+	}
+	err = stub.PutState("CodeSet_"+codeType, bytes)
+	if err != nil {
+		return errors.New("Unable to put the state")
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 validate_service_codes - Checks the codes on a service line. Revenue and bill type codes only apply to
+//							  institutional claims so may be left empty.
+//==============================================================================================================================
+func (t *SimpleChaincode) validate_service_codes(stub shim.ChaincodeStubInterface, procedureCode string, revenueCode string, billCode string) error {
+
+	err := t.validate_code(stub, CODE_PROCEDURE, procedureCode)
+	if err != nil {
+		return err
+	}
+	if revenueCode != "" {
+		err = t.validate_code(stub, CODE_REVENUE, revenueCode)
+		if err != nil {
+			return err
+		}
+	}
+	if billCode != "" {
+		err = t.validate_code(stub, CODE_BILL_TYPE, billCode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//=================================================================================================================================
+//	 Code Set Functions
+//=================================================================================================================================
+//	 load_codes - The Admin adds codes to a code table. codes is a JSON object of code to description, codes already in
+//				  the table have their description replaced.
+//=================================================================================================================================
+func (t *SimpleChaincode) load_codes(stub shim.ChaincodeStubInterface, caller string, codeType string, codes string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can load code sets")
+	}
+
+	set, err := t.retrieve_code_set(stub, codeType)
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded map[string]string
+	err = json.Unmarshal([]byte(codes), &loaded)
+	if err != nil {
+		return nil, errors.New("Codes must be a JSON object of code to description")
+	}
+	keys := make([]string, 0, len(loaded))
+	for code := range loaded {
+		keys = append(keys, code)
+	}
+	sort.Strings(keys) // Report the same invalid code on every peer
+
+	for _, code := range keys {
+		normal := normalise_code(code)
+		if !codeFormats[codeType].MatchString(normal) {
+			return nil, errors.New("Invalid " + strings.ToLower(codeType) + " code " + code)
+		}
+		set.Codes[normal] = loaded[code]
+	}
+
+	return nil, t.save_code_set(stub, codeType, set)
+}
+
+//=================================================================================================================================
+//	 set_format_only - The Admin sets whether a code type accepts any well formed code, for code sets that are not yet
+//					   available to load. formatOnly is "true" or "false".
+//=================================================================================================================================
+func (t *SimpleChaincode) set_format_only(stub shim.ChaincodeStubInterface, caller string, codeType string, formatOnly string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can configure code sets")
+	}
+	if formatOnly != "true" && formatOnly != "false" {
+		return nil, errors.New("Invalid format only setting " + formatOnly + ", expecting true or false")
+	}
+
+	set, err := t.retrieve_code_set(stub, codeType)
+	if err != nil {
+		return nil, err
+	}
+	set.FormatOnly = formatOnly == "true"
+	return nil, t.save_code_set(stub, codeType, set)
+}
+
+//=================================================================================================================================
+//	 get_code_set - Returns the codes loaded for a code type.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_code_set(stub shim.ChaincodeStubInterface, codeType string) ([]byte, error) {
+
+	set, err := t.retrieve_code_set(stub, codeType)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(set)
+}
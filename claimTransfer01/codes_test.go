@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNormaliseCode(t *testing.T) {
+
+	cases := []struct {
+		code     string
+		expected string
+	}{
+		{"A01.1", "A011"},
+		{" a01.1 ", "A011"},
+		{"S72.001A", "S72001A"},
+		{"99213", "99213"},
+		{"j1100", "J1100"},
+		{"0450", "0450"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if normal := normalise_code(c.code); normal != c.expected {
+			t.Errorf("normalise_code(%q) = %q, expected %q", c.code, normal, c.expected)
+		}
+	}
+}
+
+func TestCodeFormats(t *testing.T) {
+
+	cases := []struct {
+		codeType string
+		code     string
+		valid    bool
+	}{
+		{CODE_DIAGNOSIS, "A01", true},
+		{CODE_DIAGNOSIS, "A011", true},
+		{CODE_DIAGNOSIS, "S72001A", true},
+		{CODE_DIAGNOSIS, "S72001AB", false},
+		{CODE_DIAGNOSIS, "A0", false},
+		{CODE_DIAGNOSIS, "101", false},
+		{CODE_PROCEDURE, "99213", true},
+		{CODE_PROCEDURE, "0001F", true},
+		{CODE_PROCEDURE, "0042T", true},
+		{CODE_PROCEDURE, "J1100", true},
+		{CODE_PROCEDURE, "W1100", false},
+		{CODE_PROCEDURE, "9921", false},
+		{CODE_PROCEDURE, "992130", false},
+		{CODE_REVENUE, "0450", true},
+		{CODE_REVENUE, "450", false},
+		{CODE_REVENUE, "045A", false},
+		{CODE_BILL_TYPE, "111", true},
+		{CODE_BILL_TYPE, "0111", true},
+		{CODE_BILL_TYPE, "11", false},
+		{CODE_BILL_TYPE, "1111", false},
+	}
+	for _, c := range cases {
+		if codeFormats[c.codeType].MatchString(c.code) != c.valid {
+			t.Errorf("%s format matches %q = %v, expected %v", c.codeType, c.code, !c.valid, c.valid)
+		}
+	}
+}
+
+func TestCheckCode(t *testing.T) {
+
+	unloaded := Code_Set{Codes: map[string]string{}}
+	loaded := Code_Set{Codes: map[string]string{"A011": "Typhoid fever"}}
+	formatOnly := Code_Set{Codes: map[string]string{}, FormatOnly: true}
+	partial := Code_Set{Codes: map[string]string{"A011": "Typhoid fever"}, FormatOnly: true}
+
+	cases := []struct {
+		name  string
+		set   Code_Set
+		code  string
+		valid bool
+	}{
+		{"unloaded", unloaded, "A01.1", false},
+		{"loaded and present", loaded, "a01.1", true},
+		{"loaded and absent", loaded, "A01.2", false},
+		{"loaded and malformed", loaded, "A0", false},
+		{"format only", formatOnly, "A01.2", true},
+		{"format only and malformed", formatOnly, "A0", false},
+		{"format only with a table", partial, "A01.2", true},
+	}
+	for _, c := range cases {
+		err := check_code(c.set, CODE_DIAGNOSIS, c.code)
+		if (err == nil) != c.valid {
+			t.Errorf("%s: check_code(%q) = %v, expected valid %v", c.name, c.code, err, c.valid)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/privdata"
+)
+
+const DUPLICATE_WARNING = "POSSIBLEDUPLICATE"
+
+//==============================================================================================================================
+//	Duplicate_Holder - Holds the ClaimIDs of the claims sharing a near-duplicate key.
+//==============================================================================================================================
+type Duplicate_Holder struct {
+	ClaimIDs []string `json:"claimIds"`
+}
+
+//==============================================================================================================================
+//	 duplicate_hash - Hashes the fields passed in under the duplicateIndex key to a hex string used as a duplicate index
+//					  key. Without the key the member could be recovered by hashing every member against a known
+//					  provider, date and procedure.
+//==============================================================================================================================
+func duplicate_hash(key []byte, fields ...string) string {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join(fields, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//==============================================================================================================================
+//	 exact_duplicate_key - Claims for the same member, provider, service date, procedure and charged amount are exact
+//						   duplicates of each other.
+//==============================================================================================================================
+func exact_duplicate_key(key []byte, c Claim) string {
+	return "DupExact_" + duplicate_hash(key, c.MemberID, c.ProviderID, c.ServiceDate, c.ProcedureCode, c.ChargedAmount.String())
+}
+
+//==============================================================================================================================
+//	 near_duplicate_key - Claims for the same member, provider, service date and procedure with a different charged
+//						  amount may be duplicates and are flagged for review.
+//==============================================================================================================================
+func near_duplicate_key(key []byte, c Claim) string {
+	return "DupNear_" + duplicate_hash(key, c.MemberID, c.ProviderID, c.ServiceDate, c.ProcedureCode)
+}
+
+//==============================================================================================================================
+//	 retrieve_near_duplicates - Gets the ClaimIDs indexed under a near-duplicate key.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_near_duplicates(stub shim.ChaincodeStubInterface, key string) (Duplicate_Holder, error) {
+
+	var holder Duplicate_Holder
+
+	bytes, err := stub.GetState(key)
+	if err != nil {
+		return holder, errors.New("Unable to get duplicate index")
+	}
+	if bytes == nil {
+		return holder, nil
+	}
+	err = json.Unmarshal(bytes, &holder)
+	if err != nil {
+		return holder, errors.New("Corrupt Duplicate_Holder record")
+	}
+	return holder, nil
+}
+
+//==============================================================================================================================
+//	 check_duplicate - Rejects a new claim that exactly duplicates a claim already on the ledger, and flags it as a
+//					   possible duplicate when it matches another claim apart from the charged amount. Claims that
+//					   have been voided are ignored so a voided claim can be billed again.
+//==============================================================================================================================
+func (t *SimpleChaincode) check_duplicate(stub shim.ChaincodeStubInterface, c *Claim) error {
+
+	key, err := privdata.RequireKey(stub, COLLECTION_DUPLICATE)
+	if err != nil {
+		return err
+	}
+	bytes, err := stub.GetState(exact_duplicate_key(key, *c))
+	if err != nil {
+		return errors.New("Unable to get duplicate index")
+	}
+	if bytes != nil {
+		existing, err := t.retrieve_claim(stub, string(bytes))
+		if err != nil {
+			return err
+		}
+		if existing.ClaimStatus != STATUS_VOIDED {
+			return errors.New("Claim is a duplicate of claim " + existing.ClaimID)
+		}
+	}
+
+	holder, err := t.retrieve_near_duplicates(stub, near_duplicate_key(key, *c))
+	if err != nil {
+		return err
+	}
+	for _, id := range holder.ClaimIDs {
+		existing, err := t.retrieve_claim(stub, id)
+		if err != nil {
+			return err
+		}
+		if existing.ClaimStatus != STATUS_VOIDED {
+			c.DuplicateStatus = DUPLICATE_WARNING
+			c.DuplicateOf = append(c.DuplicateOf, id)
+		}
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 index_duplicate - Adds a newly created claim to the exact and near-duplicate indexes.
+//==============================================================================================================================
+func (t *SimpleChaincode) index_duplicate(stub shim.ChaincodeStubInterface, c Claim) error {
+
+	key, err := privdata.RequireKey(stub, COLLECTION_DUPLICATE)
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(exact_duplicate_key(key, c), []byte(c.ClaimID))
+	if err != nil {
+		return errors.New("Unable to put the state")
+	}
+
+	near := near_duplicate_key(key, c)
+	holder, err := t.retrieve_near_duplicates(stub, near)
+	if err != nil {
+		return err
+	}
+	holder.ClaimIDs = append(holder.ClaimIDs, c.ClaimID)
+
+	bytes, err := json.Marshal(holder)
+	if err != nil {
+		return errors.New("Error creating Duplicate_Holder record")
+	}
+	err = stub.PutState(near, bytes)
+	if err != nil {
+		return errors.New("Unable to put the state")
+	}
+	return nil
+}
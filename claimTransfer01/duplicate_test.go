@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ibm-blockchain/example02/privdata"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+
+	key := bytes.Repeat([]byte{1}, privdata.KEY_SIZE)
+	other := bytes.Repeat([]byte{2}, privdata.KEY_SIZE)
+	base := Claim{MemberID: "M1", ProviderID: "1234567893", ServiceDate: "2016-09-01", ProcedureCode: "99213", ChargedAmount: 10000}
+
+	cases := []struct {
+		name      string
+		change    func(c *Claim)
+		sameExact bool
+		sameNear  bool
+	}{
+		{"identical", func(c *Claim) {}, true, true},
+		{"other claim id and status", func(c *Claim) { c.ClaimID, c.ClaimStatus = "C2", STATUS_VOIDED }, true, true},
+		{"charged amount", func(c *Claim) { c.ChargedAmount = 10001 }, false, true},
+		{"member", func(c *Claim) { c.MemberID = "M2" }, false, false},
+		{"provider", func(c *Claim) { c.ProviderID = "1234567891" }, false, false},
+		{"service date", func(c *Claim) { c.ServiceDate = "2016-09-02" }, false, false},
+		{"procedure", func(c *Claim) { c.ProcedureCode = "99214" }, false, false},
+	}
+	for _, tc := range cases {
+		c := base
+		tc.change(&c)
+		if (exact_duplicate_key(key, c) == exact_duplicate_key(key, base)) != tc.sameExact {
+			t.Errorf("%s: exact key match expected %v", tc.name, tc.sameExact)
+		}
+		if (near_duplicate_key(key, c) == near_duplicate_key(key, base)) != tc.sameNear {
+			t.Errorf("%s: near key match expected %v", tc.name, tc.sameNear)
+		}
+	}
+
+	if !strings.HasPrefix(exact_duplicate_key(key, base), "DupExact_") || !strings.HasPrefix(near_duplicate_key(key, base), "DupNear_") {
+		t.Errorf("unexpected key prefixes %s, %s", exact_duplicate_key(key, base), near_duplicate_key(key, base))
+	}
+	if exact_duplicate_key(key, base)[len("DupExact_"):] == near_duplicate_key(key, base)[len("DupNear_"):] {
+		t.Errorf("exact and near keys share a hash")
+	}
+	for _, field := range []string{base.MemberID, base.ProviderID} {
+		if strings.Contains(exact_duplicate_key(key, base), field) || strings.Contains(near_duplicate_key(key, base), field) {
+			t.Errorf("duplicate key exposes %s", field)
+		}
+	}
+	if exact_duplicate_key(key, base) == exact_duplicate_key(other, base) || near_duplicate_key(key, base) == near_duplicate_key(other, base) {
+		t.Errorf("duplicate keys do not depend on the duplicateIndex key")
+	}
+}
@@ -0,0 +1,660 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	 FHIR code systems - The systems used for the coded values and identifiers in FHIR R4 Claim and ClaimResponse
+//						 resources. Ledger ids with no standard system use the claimTransfer01 URNs.
+//==============================================================================================================================
+const FHIR_NPI = "http://hl7.org/fhir/sid/us-npi"
+const FHIR_ICD10 = "http://hl7.org/fhir/sid/icd-10-cm"
+const FHIR_CPT = "http://www.ama-assn.org/go/cpt"
+const FHIR_HCPCS = "https://bluebutton.cms.gov/resources/codesystem/hcpcs"
+const FHIR_REVENUE = "https://www.nubc.org/CodeSystem/RevenueCodes"
+const FHIR_BILL_TYPE = "https://www.nubc.org/CodeSystem/TypeOfBill"
+const FHIR_ADMIT_TYPE = "https://www.nubc.org/CodeSystem/PriorityTypeOfAdmitOrVisit"
+const FHIR_ADMIT_SOURCE = "https://www.nubc.org/CodeSystem/PointOfOrigin"
+const FHIR_CLAIM_TYPE = "http://terminology.hl7.org/CodeSystem/claim-type"
+const FHIR_INFO_CATEGORY = "http://terminology.hl7.org/CodeSystem/claiminformationcategory"
+const FHIR_C4BB_INFO_CATEGORY = "http://hl7.org/fhir/us/carin-bb/CodeSystem/C4BBSupportingInfoType"
+const FHIR_ADJUDICATION = "http://terminology.hl7.org/CodeSystem/adjudication"
+const FHIR_CLAIM_ID = "urn:claimTransfer01:claimId"
+const FHIR_MEMBER_ID = "urn:claimTransfer01:memberId"
+const FHIR_SUBSCRIBER_ID = "urn:claimTransfer01:subscriberId"
+const FHIR_PLAN_CODE = "urn:claimTransfer01:planCode"
+const FHIR_NON_COVERED = "urn:claimTransfer01:nonCoveredAmount"
+
+//==============================================================================================================================
+//	 FHIR data types - The parts of the R4 data types the mapping uses.
+//==============================================================================================================================
+type FHIR_Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+type FHIR_Concept struct {
+	Coding []FHIR_Coding `json:"coding,omitempty"`
+	Text   string        `json:"text,omitempty"`
+}
+
+type FHIR_Identifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value"`
+}
+
+type FHIR_Reference struct {
+	Reference  string           `json:"reference,omitempty"`
+	Identifier *FHIR_Identifier `json:"identifier,omitempty"`
+}
+
+type FHIR_Period struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type FHIR_Money struct {
+	Value    json.Number `json:"value"`
+	Currency string      `json:"currency,omitempty"`
+}
+
+type FHIR_Quantity struct {
+	Value json.Number `json:"value"`
+}
+
+type FHIR_Extension struct {
+	URL        string      `json:"url"`
+	ValueMoney *FHIR_Money `json:"valueMoney,omitempty"`
+}
+
+//==============================================================================================================================
+//	 FHIR Claim - The parts of the R4 Claim resource the ledger Claim maps to.
+//==============================================================================================================================
+type FHIR_Diagnosis struct {
+	Sequence                 int            `json:"sequence"`
+	DiagnosisCodeableConcept FHIR_Concept   `json:"diagnosisCodeableConcept"`
+	Type                     []FHIR_Concept `json:"type,omitempty"`
+}
+
+type FHIR_SupportingInfo struct {
+	Sequence     int           `json:"sequence"`
+	Category     FHIR_Concept  `json:"category"`
+	Code         *FHIR_Concept `json:"code,omitempty"`
+	TimingDate   string        `json:"timingDate,omitempty"`
+	TimingPeriod *FHIR_Period  `json:"timingPeriod,omitempty"`
+}
+
+type FHIR_Insurance struct {
+	Sequence int            `json:"sequence"`
+	Focal    bool           `json:"focal"`
+	Coverage FHIR_Reference `json:"coverage"`
+}
+
+type FHIR_Item struct {
+	Sequence         int              `json:"sequence"`
+	Revenue          *FHIR_Concept    `json:"revenue,omitempty"`
+	ProductOrService FHIR_Concept     `json:"productOrService"`
+	ServicedDate     string           `json:"servicedDate,omitempty"`
+	Quantity         *FHIR_Quantity   `json:"quantity,omitempty"`
+	Net              *FHIR_Money      `json:"net,omitempty"`
+	Extension        []FHIR_Extension `json:"extension,omitempty"`
+}
+
+type FHIR_Claim struct {
+	ResourceType   string                `json:"resourceType"`
+	ID             string                `json:"id"`
+	Identifier     []FHIR_Identifier     `json:"identifier,omitempty"`
+	Status         string                `json:"status"`
+	Type           FHIR_Concept          `json:"type"`
+	Use            string                `json:"use"`
+	Patient        FHIR_Reference        `json:"patient"`
+	BillablePeriod *FHIR_Period          `json:"billablePeriod,omitempty"`
+	Created        string                `json:"created,omitempty"`
+	Insurer        *FHIR_Reference       `json:"insurer,omitempty"`
+	Provider       FHIR_Reference        `json:"provider"`
+	Priority       FHIR_Concept          `json:"priority"`
+	SupportingInfo []FHIR_SupportingInfo `json:"supportingInfo,omitempty"`
+	Diagnosis      []FHIR_Diagnosis      `json:"diagnosis,omitempty"`
+	Insurance      []FHIR_Insurance      `json:"insurance"`
+	Item           []FHIR_Item           `json:"item,omitempty"`
+	Total          *FHIR_Money           `json:"total,omitempty"`
+}
+
+//==============================================================================================================================
+//	 FHIR ClaimResponse - The parts of the R4 ClaimResponse resource the ledger's adjudication and payment map to.
+//==============================================================================================================================
+type FHIR_Adjudication struct {
+	Category FHIR_Concept  `json:"category"`
+	Reason   *FHIR_Concept `json:"reason,omitempty"`
+	Amount   *FHIR_Money   `json:"amount,omitempty"`
+}
+
+type FHIR_ResponseItem struct {
+	ItemSequence int                 `json:"itemSequence"`
+	Adjudication []FHIR_Adjudication `json:"adjudication"`
+}
+
+type FHIR_Total struct {
+	Category FHIR_Concept `json:"category"`
+	Amount   FHIR_Money   `json:"amount"`
+}
+
+type FHIR_Payment struct {
+	Type       FHIR_Concept     `json:"type"`
+	Date       string           `json:"date,omitempty"`
+	Amount     FHIR_Money       `json:"amount"`
+	Identifier *FHIR_Identifier `json:"identifier,omitempty"`
+}
+
+type FHIR_ClaimResponse struct {
+	ResourceType string              `json:"resourceType"`
+	ID           string              `json:"id"`
+	Status       string              `json:"status"`
+	Type         FHIR_Concept        `json:"type"`
+	Use          string              `json:"use"`
+	Patient      FHIR_Reference      `json:"patient"`
+	Created      string              `json:"created,omitempty"`
+	Insurer      *FHIR_Reference     `json:"insurer,omitempty"`
+	Requestor    *FHIR_Reference     `json:"requestor,omitempty"`
+	Request      FHIR_Reference      `json:"request"`
+	Outcome      string              `json:"outcome"`
+	Disposition  string              `json:"disposition,omitempty"`
+	Item         []FHIR_ResponseItem `json:"item,omitempty"`
+	Total        []FHIR_Total        `json:"total,omitempty"`
+	Payment      *FHIR_Payment       `json:"payment,omitempty"`
+}
+
+//==============================================================================================================================
+//	FHIR_Bundle - A collection bundle returning a claim and its response together.
+//==============================================================================================================================
+type FHIR_Entry struct {
+	Resource interface{} `json:"resource"`
+}
+
+type FHIR_Bundle struct {
+	ResourceType string       `json:"resourceType"`
+	Type         string       `json:"type"`
+	Entry        []FHIR_Entry `json:"entry"`
+}
+
+//==============================================================================================================================
+//	 fhir_money / parse_fhir_money - Amounts are FHIR decimals in US dollars.
+//==============================================================================================================================
+func fhir_money(a amount.Amount) *FHIR_Money {
+	return &FHIR_Money{Value: json.Number(a.String()), Currency: "USD"}
+}
+
+func parse_fhir_money(m *FHIR_Money) (amount.Amount, error) {
+
+	if m == nil {
+		return 0, nil
+	}
+	if m.Currency != "" && m.Currency != "USD" {
+		return 0, errors.New("Unsupported currency " + m.Currency)
+	}
+	return amount.Parse(string(m.Value))
+}
+
+//==============================================================================================================================
+//	 fhir_code - Returns a coded value as a concept with a single coding. An empty code gives nil.
+//==============================================================================================================================
+func fhir_code(system string, code string) *FHIR_Concept {
+
+	if code == "" {
+		return nil
+	}
+	return &FHIR_Concept{Coding: []FHIR_Coding{{System: system, Code: code}}}
+}
+
+//==============================================================================================================================
+//	 concept_code - Returns the code of the first coding in a concept, preferring one from the system passed in.
+//==============================================================================================================================
+func concept_code(c *FHIR_Concept, system string) string {
+
+	if c == nil || len(c.Coding) == 0 {
+		return ""
+	}
+	for _, coding := range c.Coding {
+		if coding.System == system {
+			return coding.Code
+		}
+	}
+	return c.Coding[0].Code
+}
+
+//==============================================================================================================================
+//	 concept_display - Returns the display of the coding concept_code picks, or the concept's text if it has none.
+//==============================================================================================================================
+func concept_display(c *FHIR_Concept, system string) string {
+
+	if c == nil {
+		return ""
+	}
+	code := concept_code(c, system)
+	for _, coding := range c.Coding {
+		if coding.Code == code && coding.Display != "" {
+			return coding.Display
+		}
+	}
+	return c.Text
+}
+
+//==============================================================================================================================
+//	 reference_id - Returns the identifier value of a reference, or the id of a literal "Type/id" reference.
+//==============================================================================================================================
+func reference_id(r *FHIR_Reference) string {
+
+	if r == nil {
+		return ""
+	}
+	if r.Identifier != nil {
+		return r.Identifier.Value
+	}
+	if i := strings.LastIndex(r.Reference, "/"); i >= 0 {
+		return r.Reference[i+1:]
+	}
+	return r.Reference
+}
+
+//==============================================================================================================================
+//	 procedure_system - CPT codes are numeric apart from the category II/III suffix, HCPCS Level II codes start with a
+//						letter.
+//==============================================================================================================================
+func procedure_system(code string) string {
+
+	if code != "" && code[0] >= 'A' && code[0] <= 'Z' {
+		return FHIR_HCPCS
+	}
+	return FHIR_CPT
+}
+
+//==============================================================================================================================
+//	 fhir_claim - Maps a ledger Claim to a FHIR Claim. Empty ledger fields, including those hidden from the caller,
+//				  are left out. RevenueDesc is the display of the revenue code it describes. R4 has no element for
+//				  SrvcUnitNbr, the units of each line are its item quantity, so it is dropped.
+//==============================================================================================================================
+func fhir_claim(c Claim) FHIR_Claim {
+
+	f := FHIR_Claim{
+		ResourceType: "Claim",
+		ID:           c.ClaimID,
+		Identifier:   []FHIR_Identifier{{System: FHIR_CLAIM_ID, Value: c.ClaimID}},
+		Status:       "active",
+		Type:         *fhir_code(FHIR_CLAIM_TYPE, "professional"),
+		Use:          "claim",
+		Patient:      FHIR_Reference{Identifier: &FHIR_Identifier{System: FHIR_MEMBER_ID, Value: c.MemberID}},
+		Provider:     FHIR_Reference{Identifier: &FHIR_Identifier{System: FHIR_NPI, Value: c.ProviderID}},
+		Priority:     *fhir_code("http://terminology.hl7.org/CodeSystem/processpriority", "normal"),
+		Insurance: []FHIR_Insurance{{
+			Sequence: 1,
+			Focal:    true,
+			Coverage: FHIR_Reference{Identifier: &FHIR_Identifier{System: FHIR_SUBSCRIBER_ID, Value: c.SubscriberID}},
+		}},
+	}
+	if c.ClaimStatus == STATUS_VOIDED {
+		f.Status = "cancelled"
+	}
+	if c.BillCode != "" {
+		f.Type = *fhir_code(FHIR_CLAIM_TYPE, "institutional")
+	}
+	if c.ServiceDate != "" {
+		f.BillablePeriod = &FHIR_Period{Start: c.ServiceDate, End: c.ServiceDate}
+	}
+	if len(c.States) > 0 && len(c.States[0].Entered) >= 10 {
+		f.Created = c.States[0].Entered[:10]
+	}
+	if c.RemotePlanCode != "" && c.RemotePlanCode != "UNDEFINED" {
+		f.Insurer = &FHIR_Reference{Identifier: &FHIR_Identifier{System: FHIR_PLAN_CODE, Value: c.RemotePlanCode}}
+	}
+
+	info := func(system string, category string, code *FHIR_Concept) *FHIR_SupportingInfo {
+		f.SupportingInfo = append(f.SupportingInfo, FHIR_SupportingInfo{
+			Sequence: len(f.SupportingInfo) + 1,
+			Category: *fhir_code(system, category),
+			Code:     code,
+		})
+		return &f.SupportingInfo[len(f.SupportingInfo)-1]
+	}
+	if len(c.AdmissionDate) >= 10 {
+		start := c.AdmissionDate[:10]
+		if c.AdmsnHourCode != "" {
+			start += "T" + c.AdmsnHourCode + ":00:00Z"
+		}
+		info(FHIR_INFO_CATEGORY, "admissionperiod", nil).TimingPeriod = &FHIR_Period{Start: start}
+	}
+	if c.BillCode != "" {
+		info(FHIR_C4BB_INFO_CATEGORY, "typeofbill", fhir_code(FHIR_BILL_TYPE, c.BillCode))
+	}
+	if c.AdmsnTypeCode != "" {
+		info(FHIR_C4BB_INFO_CATEGORY, "admtype", fhir_code(FHIR_ADMIT_TYPE, c.AdmsnTypeCode))
+	}
+	if c.AdmsnSrvcCode != "" {
+		info(FHIR_C4BB_INFO_CATEGORY, "pointoforigin", fhir_code(FHIR_ADMIT_SOURCE, c.AdmsnSrvcCode))
+	}
+
+	if c.DiagCode != "" {
+		f.Diagnosis = []FHIR_Diagnosis{{
+			Sequence:                 1,
+			DiagnosisCodeableConcept: *fhir_code(FHIR_ICD10, c.DiagCode),
+			Type:                     []FHIR_Concept{*fhir_code("http://terminology.hl7.org/CodeSystem/ex-diagnosistype", "principal")},
+		}}
+	}
+
+	for _, l := range c.Lines {
+		item := FHIR_Item{
+			Sequence:     l.LineNumber,
+			Revenue:      fhir_code(FHIR_REVENUE, l.RevenueCode),
+			ServicedDate: c.ProcedureDate,
+			Net:          fhir_money(l.ChargedAmount),
+		}
+		if item.Revenue != nil && l.RevenueCode == c.RevenueCode {
+			item.Revenue.Coding[0].Display = c.RevenueDesc
+		}
+		if code := fhir_code(procedure_system(l.ProcedureCode), l.ProcedureCode); code != nil {
+			item.ProductOrService = *code
+		}
+		if _, err := strconv.ParseFloat(l.UnitOfService, 64); err == nil {
+			item.Quantity = &FHIR_Quantity{Value: json.Number(l.UnitOfService)}
+		}
+		if l.NonCovAmount != 0 {
+			item.Extension = []FHIR_Extension{{URL: FHIR_NON_COVERED, ValueMoney: fhir_money(l.NonCovAmount)}}
+		}
+		f.Item = append(f.Item, item)
+	}
+	if len(c.Lines) > 0 {
+		f.Total = fhir_money(c.ChargedAmount)
+	}
+	return f
+}
+
+//==============================================================================================================================
+//	 claim_from_fhir - Maps a FHIR Claim to a ledger Claim ready for submission. Each item becomes a claim line and
+//					   the item total must match the Claim total when one is given. The display of the first item's
+//					   revenue code becomes RevenueDesc and SrvcUnitNbr is left empty.
+//==============================================================================================================================
+func claim_from_fhir(data []byte) (Claim, error) {
+
+	var f FHIR_Claim
+	var c Claim
+
+	err := json.Unmarshal(data, &f)
+	if err != nil {
+		return c, errors.New("Invalid FHIR Claim JSON")
+	}
+	if f.ResourceType != "Claim" {
+		return c, errors.New("Expecting a FHIR Claim resource, got " + f.ResourceType)
+	}
+	c.ClaimID = f.ID
+	for _, id := range f.Identifier {
+		if id.System == FHIR_CLAIM_ID {
+			c.ClaimID = id.Value
+		}
+	}
+	if c.ClaimID == "" {
+		return c, errors.New("FHIR Claim has no id")
+	}
+	c.ProviderID = reference_id(&f.Provider)
+	c.MemberID = reference_id(&f.Patient)
+	if len(f.Insurance) > 0 {
+		c.SubscriberID = reference_id(&f.Insurance[0].Coverage)
+	}
+	if c.SubscriberID == "" {
+		c.SubscriberID = c.MemberID // The member is the subscriber
+	}
+	if f.BillablePeriod != nil {
+		c.ServiceDate = f.BillablePeriod.Start
+	}
+	for _, d := range f.Diagnosis {
+		if d.Sequence == 1 || c.DiagCode == "" {
+			c.DiagCode = concept_code(&d.DiagnosisCodeableConcept, FHIR_ICD10)
+		}
+	}
+
+	for _, info := range f.SupportingInfo {
+		switch concept_code(&info.Category, "") {
+		case "admissionperiod":
+			start := info.TimingDate
+			if info.TimingPeriod != nil {
+				start = info.TimingPeriod.Start
+			}
+			if len(start) >= 10 {
+				c.AdmissionDate = start[:10]
+			}
+			if len(start) >= 13 && start[10] == 'T' {
+				c.AdmsnHourCode = start[11:13]
+			}
+		case "typeofbill":
+			c.BillCode = concept_code(info.Code, FHIR_BILL_TYPE)
+		case "admtype":
+			c.AdmsnTypeCode = concept_code(info.Code, FHIR_ADMIT_TYPE)
+		case "pointoforigin":
+			c.AdmsnSrvcCode = concept_code(info.Code, FHIR_ADMIT_SOURCE)
+		}
+	}
+	if concept_code(&f.Type, FHIR_CLAIM_TYPE) == "institutional" && c.BillCode == "" {
+		return c, errors.New("Institutional FHIR Claim has no type of bill")
+	}
+
+	if len(f.Item) == 0 {
+		return c, errors.New("FHIR Claim has no items")
+	}
+	var total amount.Amount
+	for i, item := range f.Item {
+		charged, err := parse_fhir_money(item.Net)
+		if err != nil {
+			return c, err
+		}
+		var nonCov amount.Amount
+		for _, ext := range item.Extension {
+			if ext.URL == FHIR_NON_COVERED {
+				nonCov, err = parse_fhir_money(ext.ValueMoney)
+				if err != nil {
+					return c, err
+				}
+			}
+		}
+		units := ""
+		if item.Quantity != nil {
+			units = string(item.Quantity.Value)
+		}
+		l, err := new_claim_line(i+1, concept_code(&item.ProductOrService, ""), concept_code(item.Revenue, FHIR_REVENUE), c.BillCode, units, charged.String(), nonCov.String())
+		if err != nil {
+			return c, err
+		}
+		if i == 0 {
+			c.RevenueDesc = concept_display(item.Revenue, FHIR_REVENUE)
+			c.ProcedureDate = item.ServicedDate
+			if c.ServiceDate == "" {
+				c.ServiceDate = item.ServicedDate
+			}
+		}
+		c.Lines = append(c.Lines, l)
+		total += charged
+	}
+	if f.Total != nil {
+		declared, err := parse_fhir_money(f.Total)
+		if err != nil {
+			return c, err
+		}
+		if declared != total {
+			return c, errors.New("FHIR Claim total " + declared.String() + " does not match the sum of its items " + total.String())
+		}
+	}
+	return c, nil
+}
+
+//==============================================================================================================================
+//	 fhir_adjudication - Returns an adjudication entry for an amount in one of the standard adjudication categories.
+//==============================================================================================================================
+func fhir_adjudication(category string, a amount.Amount) FHIR_Adjudication {
+	return FHIR_Adjudication{Category: *fhir_code(FHIR_ADJUDICATION, category), Amount: fhir_money(a)}
+}
+
+//==============================================================================================================================
+//	 fhir_claim_response - Maps the adjudication and payment of a ledger Claim to a FHIR ClaimResponse. The payment is
+//						   only included once the claim has been settled.
+//==============================================================================================================================
+func fhir_claim_response(c Claim, p *Payment) FHIR_ClaimResponse {
+
+	f := FHIR_ClaimResponse{
+		ResourceType: "ClaimResponse",
+		ID:           c.ClaimID,
+		Status:       "active",
+		Type:         *fhir_code(FHIR_CLAIM_TYPE, "professional"),
+		Use:          "claim",
+		Patient:      FHIR_Reference{Identifier: &FHIR_Identifier{System: FHIR_MEMBER_ID, Value: c.MemberID}},
+		Request:      FHIR_Reference{Reference: "Claim/" + c.ClaimID},
+		Outcome:      "queued",
+		Disposition:  c.ClaimStatus,
+	}
+	if c.BillCode != "" {
+		f.Type = *fhir_code(FHIR_CLAIM_TYPE, "institutional")
+	}
+	if c.ProviderID != "" {
+		f.Requestor = &FHIR_Reference{Identifier: &FHIR_Identifier{System: FHIR_NPI, Value: c.ProviderID}}
+	}
+	if c.LocalPlanCode != "" && c.LocalPlanCode != "UNDEFINED" {
+		f.Insurer = &FHIR_Reference{Identifier: &FHIR_Identifier{System: FHIR_PLAN_CODE, Value: c.LocalPlanCode}}
+	}
+	if entry, ok := current_state(c); ok && len(entry.Entered) >= 10 {
+		f.Created = entry.Entered[:10]
+	}
+	switch c.ClaimStatus {
+	case STATUS_ADJUDICATED, STATUS_PAYMENT_COMPLETE, STATUS_DENIED:
+		f.Outcome = "complete"
+	case STATUS_VOIDED:
+		f.Outcome = "complete"
+		f.Status = "cancelled"
+	}
+	if c.StatusReason != "" {
+		f.Disposition += ": " + c.StatusReason
+	}
+
+	for _, l := range c.Lines {
+		item := FHIR_ResponseItem{ItemSequence: l.LineNumber, Adjudication: []FHIR_Adjudication{fhir_adjudication("submitted", l.ChargedAmount)}}
+		if c.ClaimStatus != STATUS_INITIATED {
+			eligible := fhir_adjudication("eligible", l.ApprovedAmount)
+			if l.AdjustmentReason != "" && l.AdjustmentReason != "UNDEFINED" {
+				eligible.Reason = fhir_code("https://x12.org/codes/claim-adjustment-reason-codes", l.AdjustmentReason)
+			}
+			item.Adjudication = append(item.Adjudication, eligible)
+		}
+		f.Item = append(f.Item, item)
+	}
+
+	total := func(category string, a amount.Amount) {
+		f.Total = append(f.Total, FHIR_Total{Category: *fhir_code(FHIR_ADJUDICATION, category), Amount: *fhir_money(a)})
+	}
+	total("submitted", c.ChargedAmount)
+	if c.ClaimStatus != STATUS_INITIATED {
+		total("eligible", c.ApprovedAmount)
+		total("copay", c.CostShare)
+		total("benefit", c.FinalAmount)
+	}
+
+	if p != nil {
+		f.Payment = &FHIR_Payment{
+			Type:       *fhir_code("http://terminology.hl7.org/CodeSystem/ex-paymenttype", "complete"),
+			Date:       p.SettlementDate,
+			Amount:     *fhir_money(p.Amount),
+			Identifier: &FHIR_Identifier{Value: p.ReferenceNumber},
+		}
+	}
+	return f
+}
+
+//=================================================================================================================================
+//	 FHIR Functions
+//=================================================================================================================================
+//	 submit_fhir_claim - The Initiator creates a claim from a FHIR Claim resource. The first item is created with the
+//						 claim and the others are added as further claim lines.
+//=================================================================================================================================
+func (t *SimpleChaincode) submit_fhir_claim(stub shim.ChaincodeStubInterface, caller string, plan string, userId string, resource string) ([]byte, error) {
+
+	if caller != Initiator {
+		return nil, errors.New("Permission Denied. " + caller + " can not submit claims")
+	}
+	c, err := claim_from_fhir([]byte(resource))
+	if err != nil {
+		return nil, err
+	}
+
+	first := c.Lines[0]
+	_, err = t.create_claim(stub, caller, plan, c.ClaimID, c.ServiceDate, c.AdmissionDate, c.ProviderID, c.MemberID, c.SubscriberID,
+		c.DiagCode, first.ProcedureCode, c.ProcedureDate, c.BillCode, c.SrvcUnitNbr, first.RevenueCode, c.RevenueDesc,
+		c.AdmsnHourCode, c.AdmsnTypeCode, c.AdmsnSrvcCode, first.UnitOfService, first.ChargedAmount.String(),
+		first.NonCovAmount.String(), userId)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := t.retrieve_claim(stub, c.ClaimID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range c.Lines[1:] {
+		_, err = t.add_claim_line(stub, c.ClaimID, stored, caller, l.ProcedureCode, l.RevenueCode, l.BillCode, l.UnitOfService, l.ChargedAmount.String(), l.NonCovAmount.String())
+		if err != nil {
+			return nil, err
+		}
+		err = t.record_invoke_history(stub, "add_claim_line", userId, c.ClaimID, stored)
+		if err != nil {
+			return nil, err
+		}
+		stored, err = t.retrieve_claim(stub, c.ClaimID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []byte(c.ClaimID), nil
+}
+
+//=================================================================================================================================
+//	 get_claim_fhir - Returns a claim as a FHIR Bundle holding its Claim and ClaimResponse. Only the fields the caller
+//					  may see, and the private fields it passed the keys for, are mapped.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_claim_fhir(stub shim.ChaincodeStubInterface, c Claim, caller string) ([]byte, error) {
+
+	err := t.reveal_private(stub, &c, caller)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := project_claim(c, caller)
+	if err != nil {
+		return nil, err
+	}
+	var visible Claim
+	err = json.Unmarshal(bytes, &visible)
+	if err != nil {
+		return nil, errors.New("Unmarshalling failed for claim")
+	}
+
+	var payment *Payment
+	if visible.PaymentRef != "" {
+		p, found, err := t.retrieve_payment(stub, visible.PaymentRef)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			payment = &p
+		}
+	}
+
+	bundle := FHIR_Bundle{
+		ResourceType: "Bundle",
+		Type:         "collection",
+		Entry: []FHIR_Entry{
+			{Resource: fhir_claim(visible)},
+			{Resource: fhir_claim_response(visible, payment)},
+		},
+	}
+	return json.Marshal(bundle)
+}
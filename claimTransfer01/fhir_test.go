@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func fhir_test_claim(t *testing.T, billCode string, lines ...[]string) Claim {
+
+	c := Claim{
+		ClaimID:       "C1",
+		ServiceDate:   "2024-01-02",
+		ProviderID:    "1234567893",
+		MemberID:      "MEM1",
+		SubscriberID:  "SUB1",
+		DiagCode:      "A01.1",
+		ProcedureDate: "2024-01-02",
+		BillCode:      billCode,
+		RevenueCode:   lines[0][1],
+		RevenueDesc:   "Emergency room",
+	}
+	for i, l := range lines {
+		line, err := new_claim_line(i+1, l[0], l[1], billCode, l[2], l[3], l[4])
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Lines = append(c.Lines, line)
+		c.ChargedAmount += line.ChargedAmount
+	}
+	return c
+}
+
+func TestFHIRClaimRoundTrip(t *testing.T) {
+
+	professional := fhir_test_claim(t, "", []string{"99213", "", "1", "100.00", "10.00"}, []string{"J1100", "", "2", "50.50", "0"})
+	institutional := fhir_test_claim(t, "111", []string{"99213", "0450", "1", "100.00", "0"}, []string{"99214", "0451", "3", "25.25", "5.00"})
+	institutional.AdmissionDate = "2024-01-01"
+	institutional.AdmsnHourCode = "10"
+	institutional.AdmsnTypeCode = "1"
+	institutional.AdmsnSrvcCode = "2"
+
+	cases := []struct {
+		name      string
+		claim     Claim
+		claimType string
+	}{
+		{"professional", professional, "professional"},
+		{"institutional", institutional, "institutional"},
+	}
+	for _, c := range cases {
+		f := fhir_claim(c.claim)
+		if code := concept_code(&f.Type, FHIR_CLAIM_TYPE); code != c.claimType {
+			t.Errorf("%s: claim type %s, expected %s", c.name, code, c.claimType)
+		}
+		data, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := claim_from_fhir(data)
+		if err != nil {
+			t.Fatalf("%s: claim_from_fhir = %v", c.name, err)
+		}
+
+		expected := c.claim
+		expected.ChargedAmount = 0 // claim_from_fhir only returns the lines, create_claim derives the totals
+		expected.RevenueCode = ""  // and takes the revenue code from the first line
+		if c.claim.RevenueCode == "" {
+			expected.RevenueDesc = "" // A line without a revenue code has nowhere to carry its description
+		}
+		if !reflect.DeepEqual(back, expected) {
+			t.Errorf("%s: round trip gave\n%+v\nexpected\n%+v", c.name, back, expected)
+		}
+	}
+}
+
+func TestFHIRClaimErrors(t *testing.T) {
+
+	valid := fhir_claim(fhir_test_claim(t, "111", []string{"99213", "0450", "1", "100.00", "0"}))
+	cases := []struct {
+		name   string
+		change func(f *FHIR_Claim)
+		err    string
+	}{
+		{"wrong resource", func(f *FHIR_Claim) { f.ResourceType = "Patient" }, "Expecting a FHIR Claim"},
+		{"no id", func(f *FHIR_Claim) { f.ID, f.Identifier = "", nil }, "has no id"},
+		{"no items", func(f *FHIR_Claim) { f.Item = nil; f.Total = nil }, "has no items"},
+		{"no type of bill", func(f *FHIR_Claim) { f.SupportingInfo = nil }, "no type of bill"},
+		{"total mismatch", func(f *FHIR_Claim) { f.Total = &FHIR_Money{Value: "99.99"} }, "does not match"},
+		{"other currency", func(f *FHIR_Claim) { f.Item[0].Net.Currency = "EUR" }, "Unsupported currency"},
+		{"bad amount", func(f *FHIR_Claim) { f.Item[0].Net.Value = "1e3" }, "Invalid amount"},
+	}
+	for _, c := range cases {
+		var f FHIR_Claim
+		data, _ := json.Marshal(valid)
+		json.Unmarshal(data, &f)
+		c.change(&f)
+		data, _ = json.Marshal(f)
+		_, err := claim_from_fhir(data)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: claim_from_fhir = %v, expected an error containing %q", c.name, err, c.err)
+		}
+	}
+}
+
+func TestFHIRClaimResponse(t *testing.T) {
+
+	c := fhir_test_claim(t, "111", []string{"99213", "0450", "1", "100.00", "0"}, []string{"99214", "0451", "2", "50.00", "0"})
+	c.ClaimStatus = STATUS_INITIATED
+	c.LocalPlanCode = "L"
+
+	f := fhir_claim_response(c, nil)
+	if f.Outcome != "queued" || f.Request.Reference != "Claim/C1" || f.Insurer.Identifier.Value != "L" || f.Payment != nil {
+		t.Errorf("initiated claim response %+v", f)
+	}
+	if len(f.Total) != 1 || concept_code(&f.Total[0].Category, "") != "submitted" || f.Total[0].Amount.Value != "150.00" {
+		t.Errorf("initiated claim totals %+v", f.Total)
+	}
+
+	c.ClaimStatus = STATUS_PAYMENT_COMPLETE
+	c.Lines[0].ApprovedAmount, c.Lines[0].AdjustmentReason = 8000, "CO45"
+	c.Lines[1].ApprovedAmount = 5000
+	c.ApprovedAmount, c.CostShare, c.FinalAmount = 13000, 2000, 11000
+	p := Payment{ReferenceNumber: "R1", Amount: 11000, SettlementDate: "2024-02-01"}
+
+	f = fhir_claim_response(c, &p)
+	if f.Outcome != "complete" || concept_code(&f.Type, "") != "institutional" {
+		t.Errorf("paid claim response %+v", f)
+	}
+	totals := map[string]json.Number{}
+	for _, total := range f.Total {
+		totals[concept_code(&total.Category, "")] = total.Amount.Value
+	}
+	expected := map[string]json.Number{"submitted": "150.00", "eligible": "130.00", "copay": "20.00", "benefit": "110.00"}
+	if !reflect.DeepEqual(totals, expected) {
+		t.Errorf("paid claim totals %v, expected %v", totals, expected)
+	}
+	if len(f.Item) != 2 || len(f.Item[0].Adjudication) != 2 || concept_code(f.Item[0].Adjudication[1].Reason, "") != "CO45" || f.Item[1].Adjudication[1].Reason != nil {
+		t.Errorf("paid claim items %+v", f.Item)
+	}
+	if f.Payment == nil || f.Payment.Amount.Value != "110.00" || f.Payment.Identifier.Value != "R1" || f.Payment.Date != "2024-02-01" {
+		t.Errorf("paid claim payment %+v", f.Payment)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	ClaimLine - A single revenue/procedure service line on a Claim. The claim level ChargedAmount, NonCovAmount and
+//				ApprovedAmount are the sums of the matching line amounts. AdjustmentAmount is the part of the covered
+//				charge the Host did not approve i.e. ChargedAmount - NonCovAmount - ApprovedAmount.
+//==============================================================================================================================
+type ClaimLine struct {
+	LineNumber       int           `json:"lineNumber"`
+	ProcedureCode    string        `json:"procedureCode"`
+	RevenueCode      string        `json:"revenueCode"`
+	BillCode         string        `json:"billCode"`
+	UnitOfService    string        `json:"unitOfService"`
+	ChargedAmount    amount.Amount `json:"chargedAmount"`
+	NonCovAmount     amount.Amount `json:"nonCovAmount"`
+	ApprovedAmount   amount.Amount `json:"approvedAmount"`
+	AdjustmentAmount amount.Amount `json:"adjustmentAmount"`
+	AdjustmentReason string        `json:"adjustmentReason"`
+}
+
+//==============================================================================================================================
+//	 new_claim_line - Builds a ClaimLine from the values supplied by the front end, validating the charge amounts.
+//==============================================================================================================================
+func new_claim_line(lineNumber int, procedureCode string, revenueCode string, billCode string, unitOfService string, chargedAmt string, nonCovAmt string) (ClaimLine, error) {
+
+	var l ClaimLine
+
+	charged, err := parse_charged_amount(chargedAmt)
+	if err != nil {
+		return l, errors.New("Invalid line charged amount: " + err.Error())
+	}
+	nonCov, err := amount.Parse(nonCovAmt)
+	if err != nil {
+		return l, errors.New("Invalid line non covered amount: " + err.Error())
+	}
+
+	l.LineNumber = lineNumber
+	l.ProcedureCode = procedureCode
+	l.RevenueCode = revenueCode
+	l.BillCode = billCode
+	l.UnitOfService = unitOfService
+	l.ChargedAmount = charged
+	l.NonCovAmount = nonCov
+	l.AdjustmentAmount = charged - nonCov
+	l.AdjustmentReason = "UNDEFINED"
+
+	return l, nil
+}
+
+//==============================================================================================================================
+//	 validate_line - Applies the claim level amount invariants to a single line.
+//==============================================================================================================================
+func validate_line(l ClaimLine) error {
+
+	if l.ChargedAmount < 0 || l.NonCovAmount < 0 || l.ApprovedAmount < 0 {
+		return fmt.Errorf("Line %d amounts must not be negative", l.LineNumber)
+	}
+	if l.NonCovAmount > l.ChargedAmount {
+		return fmt.Errorf("Line %d non covered amount %s exceeds charged amount %s", l.LineNumber, l.NonCovAmount, l.ChargedAmount)
+	}
+	if l.ApprovedAmount > l.ChargedAmount-l.NonCovAmount {
+		return fmt.Errorf("Line %d approved amount %s exceeds covered amount %s", l.LineNumber, l.ApprovedAmount, l.ChargedAmount-l.NonCovAmount)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 derive_totals - Recalculates the claim level amounts from the line sums. Claims recorded before lines were
+//					 introduced have no lines and keep the amounts they were created with. Each line is within
+//					 MAX_AMOUNT_UNITS but their sum may not be, so a claim whose totals overflow is rejected.
+//==============================================================================================================================
+func derive_totals(c *Claim) error {
+
+	if len(c.Lines) == 0 {
+		return nil
+	}
+
+	var charged, nonCov, approved amount.Amount
+	for i := range c.Lines {
+		err := validate_line(c.Lines[i])
+		if err != nil {
+			return err
+		}
+		c.Lines[i].AdjustmentAmount = c.Lines[i].ChargedAmount - c.Lines[i].NonCovAmount - c.Lines[i].ApprovedAmount
+		charged, err = amount.Add(charged, c.Lines[i].ChargedAmount)
+		if err != nil {
+			return err
+		}
+		nonCov, err = amount.Add(nonCov, c.Lines[i].NonCovAmount)
+		if err != nil {
+			return err
+		}
+		approved, err = amount.Add(approved, c.Lines[i].ApprovedAmount)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.ChargedAmount = charged
+	c.NonCovAmount = nonCov
+	c.ApprovedAmount = approved
+	c.FinalAmount = c.ApprovedAmount - c.CostShare
+
+	return nil
+}
+
+//==============================================================================================================================
+//	 find_line - Returns the index of the line with the line number passed in.
+//==============================================================================================================================
+func find_line(c Claim, lineNumber string) (int, error) {
+
+	n, err := strconv.Atoi(lineNumber)
+	if err != nil {
+		return -1, errors.New("Invalid line number " + lineNumber)
+	}
+	for i := range c.Lines {
+		if c.Lines[i].LineNumber == n {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("Line %d does not exist on claim %s", n, c.ClaimID)
+}
+
+//=================================================================================================================================
+//	 Line Functions
+//=================================================================================================================================
+//	 add_claim_line - The Initiator adds a further service line to a claim before it is sent to the Host or while it
+//					  has been returned for correction.
+//=================================================================================================================================
+func (t *SimpleChaincode) add_claim_line(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, procedureCode string, revenueCode string, billCode string, unitOfService string, chargedAmt string, nonCovAmt string) ([]byte, error) {
+
+	if caller != Initiator {
+		return nil, errors.New("Only the Initiator can add claim lines")
+	}
+	err := t.validate_service_codes(stub, procedureCode, revenueCode, billCode)
+	if err != nil {
+		return nil, err
+	}
+
+	next := 1
+	for _, l := range c.Lines {
+		if l.LineNumber >= next {
+			next = l.LineNumber + 1
+		}
+	}
+
+	l, err := new_claim_line(next, procedureCode, revenueCode, billCode, unitOfService, chargedAmt, nonCovAmt)
+	if err != nil {
+		return nil, err
+	}
+	c.Lines = append(c.Lines, l)
+
+	err = derive_totals(&c)
+	if err != nil {
+		return nil, err
+	}
+	err = validate_amounts(c)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+
+	return []byte(strconv.Itoa(next)), nil // We are Done
+
+}
+
+//=================================================================================================================================
+//	 update_claim_line - The Initiator corrects the codes or charges on an existing line before Host approval or while
+//						 the claim has been returned for correction.
+//=================================================================================================================================
+func (t *SimpleChaincode) update_claim_line(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, lineNumber string, procedureCode string, revenueCode string, billCode string, unitOfService string, chargedAmt string, nonCovAmt string) ([]byte, error) {
+
+	if caller != Initiator {
+		return nil, errors.New("Only the Initiator can update claim lines")
+	}
+	i, err := find_line(c, lineNumber)
+	if err != nil {
+		return nil, err
+	}
+	err = t.validate_service_codes(stub, procedureCode, revenueCode, billCode)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := new_claim_line(c.Lines[i].LineNumber, procedureCode, revenueCode, billCode, unitOfService, chargedAmt, nonCovAmt)
+	if err != nil {
+		return nil, err
+	}
+	c.Lines[i] = l
+
+	err = derive_totals(&c)
+	if err != nil {
+		return nil, err
+	}
+	err = validate_amounts(c)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+
+	return nil, nil // We are Done
+
+}
+
+//=================================================================================================================================
+//	 approve_claim_line - The Host sets the approved amount and adjustment reason for a line before approving the claim.
+//=================================================================================================================================
+func (t *SimpleChaincode) approve_claim_line(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, lineNumber string, approvedAmt string, adjustmentReason string) ([]byte, error) {
+
+	if caller != Host {
+		return nil, errors.New("Only the Host can approve claim lines")
+	}
+	i, err := find_line(c, lineNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	approved, err := amount.Parse(approvedAmt)
+	if err != nil {
+		return nil, errors.New("Invalid line approved amount: " + err.Error())
+	}
+	c.Lines[i].ApprovedAmount = approved
+	c.Lines[i].AdjustmentReason = adjustmentReason
+
+	err = derive_totals(&c)
+	if err != nil {
+		return nil, err
+	}
+	err = validate_amounts(c)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+
+	return nil, nil // We are Done
+
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+func TestDeriveTotals(t *testing.T) {
+
+	largest := amount.Amount(amount.MAX_UNITS * 100)
+	cases := []struct {
+		name    string
+		charged []amount.Amount
+		total   amount.Amount
+		valid   bool
+	}{
+		{"one line", []amount.Amount{10000}, 10000, true},
+		{"two lines", []amount.Amount{10000, 5050}, 15050, true},
+		{"largest line", []amount.Amount{largest}, largest, true},
+		{"overflowing lines", []amount.Amount{largest, largest}, 0, false},
+		{"many lines", []amount.Amount{largest / 4, largest / 4, largest / 4, largest / 4, largest / 4}, 0, false},
+	}
+	for _, c := range cases {
+		var claim Claim
+		for i, charged := range c.charged {
+			claim.Lines = append(claim.Lines, ClaimLine{LineNumber: i + 1, ChargedAmount: charged})
+		}
+		err := derive_totals(&claim)
+		if c.valid && (err != nil || claim.ChargedAmount != c.total) {
+			t.Errorf("%s: derive_totals = %v, charged %s, expected %s", c.name, err, claim.ChargedAmount, c.total)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("%s: derive_totals charged %s, expected an error", c.name, claim.ChargedAmount)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/privdata"
+)
+
+//==============================================================================================================================
+//	Coverage - A period during which a member is covered by a plan. An empty End means the coverage is open ended.
+//==============================================================================================================================
+type Coverage struct {
+	PlanCode string `json:"planCode"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+}
+
+//==============================================================================================================================
+//	Member - A member of a plan and their coverage history. Stored on the ledger under the key returned by member_key,
+//			 with the member and subscriber ids sealed in the memberPHI collection like those on a claim.
+//==============================================================================================================================
+type Member struct {
+	MemberID      string            `json:"memberId"`
+	SubscriberID  string            `json:"subscriberId"`
+	Coverages     []Coverage        `json:"coverages"`
+	PrivateSalt   string            `json:"privateSalt,omitempty"`
+	PrivateHashes map[string]string `json:"privateHashes,omitempty"`
+}
+
+//==============================================================================================================================
+//	 parse_date - Parses a date given as YYYY-MM-DD or, as used in X12, YYYYMMDD.
+//==============================================================================================================================
+func parse_date(value string) (time.Time, error) {
+
+	d, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		d, err = time.Parse("20060102", value)
+	}
+	if err != nil {
+		return d, errors.New("Invalid date " + value + ", expecting YYYY-MM-DD")
+	}
+	return d, nil
+}
+
+//==============================================================================================================================
+//	 covers - Returns true if the coverage period includes the date passed in.
+//==============================================================================================================================
+func (cov Coverage) covers(date time.Time) bool {
+
+	start, err := parse_date(cov.Start)
+	if err != nil || date.Before(start) {
+		return false
+	}
+	if cov.End == "" {
+		return true
+	}
+	end, err := parse_date(cov.End)
+	return err == nil && !date.After(end)
+}
+
+//==============================================================================================================================
+//	 member_fields - Returns the private fields of a member.
+//==============================================================================================================================
+func member_fields(m *Member) []privdata.Field {
+
+	return []privdata.Field{
+		{Collection: COLLECTION_MEMBER, Name: "memberId", Value: &m.MemberID},
+		{Collection: COLLECTION_MEMBER, Name: "subscriberId", Value: &m.SubscriberID},
+	}
+}
+
+//==============================================================================================================================
+//	 member_key - Returns the ledger key of a member, "Member_" + the hash of the member id under the memberPHI key, so
+//				  the member id is not on the ledger and only callers passing the key can look members up.
+//==============================================================================================================================
+func member_key(stub shim.ChaincodeStubInterface, memberId string) (string, error) {
+
+	key, err := privdata.RequireKey(stub, COLLECTION_MEMBER)
+	if err != nil {
+		return "", err
+	}
+	return "Member_" + privdata.Hash(key, "", "memberId", memberId), nil
+}
+
+//==============================================================================================================================
+//	 retrieve_member - Gets a Member from the ledger. Returns false if the member has not been registered. Members
+//					   registered before their ids were kept private are still found under "Member_" + MemberID, and
+//					   are moved the next time they are saved.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_member(stub shim.ChaincodeStubInterface, memberId string) (Member, bool, error) {
+
+	var m Member
+
+	id, err := member_key(stub, memberId)
+	if err != nil {
+		return m, false, err
+	}
+	bytes, err := stub.GetState(id)
+	if err == nil && bytes == nil {
+		bytes, err = stub.GetState("Member_" + memberId)
+	}
+	if err != nil {
+		return m, false, errors.New("Unable to get member " + memberId)
+	}
+	if bytes == nil {
+		return m, false, nil
+	}
+	err = json.Unmarshal(bytes, &m)
+	if err != nil {
+		return m, false, errors.New("Corrupt Member record " + memberId)
+	}
+	return m, true, nil
+}
+
+//==============================================================================================================================
+//	 save_member - Seals the ids of a Member and writes it to the ledger, removing any record of it held in clear.
+//==============================================================================================================================
+func (t *SimpleChaincode) save_member(stub shim.ChaincodeStubInterface, memberId string, m Member) error {
+
+	id, err := member_key(stub, memberId)
+	if err != nil {
+		return err
+	}
+	hashes := privdata.Hashes{Salt: m.PrivateSalt, Values: m.PrivateHashes}
+	err = privateCollections.Seal(stub, id, member_fields(&m), &hashes)
+	if err != nil {
+		return err
+	}
+	m.PrivateSalt = hashes.Salt
+	m.PrivateHashes = hashes.Values
+
+	bytes, err := json.Marshal(m)
+	if err != nil {
+		return errors.New("Error converting Member record")
+	}
+	err = stub.PutState(id, bytes)
+	if err != nil {
+		return errors.New("Error storing Member record")
+	}
+	err = stub.DelState("Member_" + memberId)
+	if err != nil {
+		return errors.New("Error removing Member record " + memberId)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 subscriber_matches - Returns true if the subscriber passed in is the one registered for a member.
+//==============================================================================================================================
+func subscriber_matches(stub shim.ChaincodeStubInterface, m Member, subscriberId string) (bool, error) {
+
+	if m.SubscriberID != "" { // Not sealed yet
+		return m.SubscriberID == subscriberId, nil
+	}
+	key, err := privdata.RequireKey(stub, COLLECTION_MEMBER)
+	if err != nil {
+		return false, err
+	}
+	return privdata.Hash(key, m.PrivateSalt, "subscriberId", subscriberId) == m.PrivateHashes["subscriberId"], nil
+}
+
+//==============================================================================================================================
+//	 eligible_coverage - Returns the coverage under which a member is eligible on a service date. The subscriber must
+//						 match the one registered for the member.
+//==============================================================================================================================
+func (t *SimpleChaincode) eligible_coverage(stub shim.ChaincodeStubInterface, memberId string, subscriberId string, serviceDate string) (Coverage, error) {
+
+	date, err := parse_date(serviceDate)
+	if err != nil {
+		return Coverage{}, err
+	}
+	m, found, err := t.retrieve_member(stub, memberId)
+	if err != nil {
+		return Coverage{}, err
+	}
+	if !found {
+		return Coverage{}, errors.New("Member " + memberId + " is not registered")
+	}
+	matches, err := subscriber_matches(stub, m, subscriberId)
+	if err != nil {
+		return Coverage{}, err
+	}
+	if !matches {
+		return Coverage{}, errors.New("Subscriber " + subscriberId + " does not match member " + memberId)
+	}
+	for _, cov := range m.Coverages {
+		if cov.covers(date) {
+			return cov, nil
+		}
+	}
+	return Coverage{}, errors.New("Member " + memberId + " was not covered on " + serviceDate)
+}
+
+//=================================================================================================================================
+//	 Member Functions
+//=================================================================================================================================
+//	 register_member - The Admin or Home plan registers a member and their subscriber. Like the other member functions it
+//					   needs the memberPHI key in the transaction metadata.
+//=================================================================================================================================
+func (t *SimpleChaincode) register_member(stub shim.ChaincodeStubInterface, caller string, memberId string, subscriberId string) ([]byte, error) {
+
+	if caller != Admin && caller != Home {
+		return nil, errors.New("Only the Admin or Home plan can register members")
+	}
+	if memberId == "" || subscriberId == "" {
+		return nil, errors.New("Member and subscriber ids are required")
+	}
+
+	m, found, err := t.retrieve_member(stub, memberId)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, errors.New("Member " + memberId + " already exists")
+	}
+	m.MemberID = memberId
+	m.SubscriberID = subscriberId
+
+	return nil, t.save_member(stub, memberId, m)
+}
+
+//=================================================================================================================================
+//	 add_coverage - The Admin or Home plan records a coverage period for a member. A Home plan participant can only
+//					record coverage under the plan they act for. Recording the same plan and start date again replaces
+//					the end date, which is how coverage is terminated. Periods may not overlap.
+//=================================================================================================================================
+func (t *SimpleChaincode) add_coverage(stub shim.ChaincodeStubInterface, caller string, callerPlan string, memberId string, planCode string, start string, end string) ([]byte, error) {
+
+	if caller != Admin && caller != Home {
+		return nil, errors.New("Only the Admin or Home plan can maintain coverage")
+	}
+	if planCode == "" {
+		return nil, errors.New("A plan code is required")
+	}
+	if caller == Home && callerPlan != "" && planCode != callerPlan {
+		return nil, errors.New("Permission Denied. " + caller + " acts for plan " + callerPlan + " and can not maintain " + planCode + " coverage")
+	}
+	startDate, err := parse_date(start)
+	if err != nil {
+		return nil, err
+	}
+	cov := Coverage{PlanCode: planCode, Start: startDate.Format("2006-01-02")}
+	if end != "" {
+		endDate, err := parse_date(end)
+		if err != nil {
+			return nil, err
+		}
+		if endDate.Before(startDate) {
+			return nil, errors.New("Coverage can not end before it starts")
+		}
+		cov.End = endDate.Format("2006-01-02")
+	}
+
+	m, found, err := t.retrieve_member(stub, memberId)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("Member " + memberId + " is not registered")
+	}
+
+	coverages := []Coverage{}
+	for _, existing := range m.Coverages {
+		if existing.PlanCode == cov.PlanCode && existing.Start == cov.Start {
+			continue // Replaced by the new period
+		}
+		if overlaps(existing, cov) {
+			return nil, errors.New("Coverage overlaps the " + existing.PlanCode + " coverage starting " + existing.Start)
+		}
+		coverages = append(coverages, existing)
+	}
+	m.Coverages = append(coverages, cov)
+
+	return nil, t.save_member(stub, memberId, m)
+}
+
+//==============================================================================================================================
+//	 overlaps - Returns true if two coverage periods share any day.
+//==============================================================================================================================
+func overlaps(a Coverage, b Coverage) bool {
+
+	aStart, _ := parse_date(a.Start)
+	bStart, _ := parse_date(b.Start)
+	return a.covers(bStart) || b.covers(aStart)
+}
+
+//=================================================================================================================================
+//	 get_member - Returns a member and their coverage. The member and subscriber ids are only filled in for callers that
+//				  may read the memberPHI collection.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_member(stub shim.ChaincodeStubInterface, caller string, memberId string) ([]byte, error) {
+
+	if caller != Admin && caller != Home && caller != Host && caller != Initiator {
+		return nil, errors.New("Permission Denied. " + caller + " can not view members")
+	}
+	m, found, err := t.retrieve_member(stub, memberId)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("Member " + memberId + " is not registered")
+	}
+	id, err := member_key(stub, memberId)
+	if err != nil {
+		return nil, err
+	}
+	err = privateCollections.Reveal(stub, id, member_fields(&m), caller)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+//=================================================================================================================================
+//	 check_eligibility - Returns the coverage a member is eligible under on a service date.
+//=================================================================================================================================
+func (t *SimpleChaincode) check_eligibility(stub shim.ChaincodeStubInterface, caller string, memberId string, subscriberId string, serviceDate string) ([]byte, error) {
+
+	if caller != Admin && caller != Home && caller != Host && caller != Initiator {
+		return nil, errors.New("Permission Denied. " + caller + " can not check eligibility")
+	}
+	cov, err := t.eligible_coverage(stub, memberId, subscriberId, serviceDate)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(cov)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	Payment - Settlement record created when the Host completes payment of a claim. The member's Home plan
+//			  (RemotePlanCode) pays the Host plan (LocalPlanCode). Payments are keyed by reference number.
+//==============================================================================================================================
+type Payment struct {
+	ReferenceNumber string        `json:"referenceNumber"`
+	ClaimID         string        `json:"claimId"`
+	PayerPlan       string        `json:"payerPlan"`
+	PayeePlan       string        `json:"payeePlan"`
+	Amount          amount.Amount `json:"amount"`
+	PaymentMethod   string        `json:"paymentMethod"`
+	SettlementDate  string        `json:"settlementDate"`
+}
+
+//==============================================================================================================================
+//	Payment_Holder - Holds the reference numbers of every Payment created. Used as an index when reconciling plans.
+//==============================================================================================================================
+type Payment_Holder struct {
+	References []string `json:"references"`
+}
+
+//==============================================================================================================================
+//	Settlement_Summary - Total settled between a Host (LocalPlanCode) and Home (RemotePlanCode) plan pair.
+//==============================================================================================================================
+type Settlement_Summary struct {
+	LocalPlanCode  string        `json:"localPlanCode"`
+	RemotePlanCode string        `json:"remotePlanCode"`
+	PaymentCount   int           `json:"paymentCount"`
+	SettledAmount  amount.Amount `json:"settledAmount"`
+}
+
+//==============================================================================================================================
+//	 payment_key - Ledger key holding the Payment with the reference number passed in.
+//==============================================================================================================================
+func payment_key(referenceNumber string) string {
+	return "Payment_" + referenceNumber
+}
+
+//==============================================================================================================================
+//	 retrieve_payment - Gets a Payment from the ledger. Returns false if no payment has the reference number.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_payment(stub shim.ChaincodeStubInterface, referenceNumber string) (Payment, bool, error) {
+
+	var p Payment
+
+	bytes, err := stub.GetState(payment_key(referenceNumber))
+	if err != nil {
+		return p, false, errors.New("Unable to get payment " + referenceNumber)
+	}
+	if bytes == nil {
+		return p, false, nil
+	}
+	err = json.Unmarshal(bytes, &p)
+	if err != nil {
+		return p, false, errors.New("Corrupt Payment record " + referenceNumber)
+	}
+	return p, true, nil
+}
+
+//==============================================================================================================================
+//	 retrieve_payment_refs - Gets the index of payment reference numbers from the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_payment_refs(stub shim.ChaincodeStubInterface) (Payment_Holder, error) {
+
+	var refs Payment_Holder
+
+	bytes, err := stub.GetState("PaymentRefs")
+	if err != nil {
+		return refs, errors.New("Unable to get PaymentRefs")
+	}
+	if bytes == nil {
+		return refs, nil
+	}
+	err = json.Unmarshal(bytes, &refs)
+	if err != nil {
+		return refs, errors.New("Corrupt Payment_Holder record")
+	}
+	return refs, nil
+}
+
+//==============================================================================================================================
+//	 save_payment - Writes a new Payment to the ledger and adds its reference number to the index.
+//==============================================================================================================================
+func (t *SimpleChaincode) save_payment(stub shim.ChaincodeStubInterface, p Payment) error {
+
+	bytes, err := json.Marshal(p)
+	if err != nil {
+		return errors.New("Error converting Payment record")
+	}
+	err = stub.PutState(payment_key(p.ReferenceNumber), bytes)
+	if err != nil {
+		logger.Errorf("SAVE_PAYMENT: Error storing Payment record: %s", err)
+		return errors.New("Error storing Payment record")
+	}
+
+	refs, err := t.retrieve_payment_refs(stub)
+	if err != nil {
+		return err
+	}
+	refs.References = append(refs.References, p.ReferenceNumber)
+
+	bytes, err = json.Marshal(refs)
+	if err != nil {
+		return errors.New("Error converting Payment_Holder record")
+	}
+	err = stub.PutState("PaymentRefs", bytes)
+	if err != nil {
+		return errors.New("Unable to put the state")
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 create_payment - Creates the settlement record for a claim. Creating a payment is idempotent on the reference
+//					  number: repeating the same settlement returns false without writing anything, while reusing a
+//					  reference number for a different settlement is an error.
+//==============================================================================================================================
+func (t *SimpleChaincode) create_payment(stub shim.ChaincodeStubInterface, c Claim, referenceNumber string) (bool, error) {
+
+	if referenceNumber == "" {
+		return false, errors.New("A payment reference number is required")
+	}
+
+	existing, found, err := t.retrieve_payment(stub, referenceNumber)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		if existing.ClaimID != c.ClaimID || existing.Amount != c.FinalAmount || existing.PaymentMethod != c.PaymentMethod {
+			return false, errors.New("Payment reference " + referenceNumber + " has already been used for a different settlement")
+		}
+		return false, nil
+	}
+
+	settled, err := tx_time(stub)
+	if err != nil {
+		return false, err
+	}
+
+	p := Payment{
+		ReferenceNumber: referenceNumber,
+		ClaimID:         c.ClaimID,
+		PayerPlan:       c.RemotePlanCode,
+		PayeePlan:       c.LocalPlanCode,
+		Amount:          c.FinalAmount,
+		PaymentMethod:   c.PaymentMethod,
+		SettlementDate:  settled.Format("2006-01-02"),
+	}
+	return true, t.save_payment(stub, p)
+}
+
+//=================================================================================================================================
+//	 get_payment - Returns the Payment with the reference number passed in. A caller acting for a plan can only see
+//				   payments made or received by that plan.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_payment(stub shim.ChaincodeStubInterface, referenceNumber string, caller string, plan string) ([]byte, error) {
+
+	if caller != Host && caller != Home && caller != CFA {
+		return nil, errors.New("Permission Denied. " + caller + " can not view payments")
+	}
+
+	p, found, err := t.retrieve_payment(stub, referenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("Payment " + referenceNumber + " does not exist")
+	}
+	if plan != "" && plan != p.PayerPlan && plan != p.PayeePlan {
+		return nil, errors.New("Permission Denied. Payment " + referenceNumber + " is not for plan " + plan)
+	}
+	return json.Marshal(p)
+}
+
+//=================================================================================================================================
+//	 settlement_summaries - Totals every Payment by (LocalPlanCode, RemotePlanCode) pair, ordered by plan codes.
+//=================================================================================================================================
+func (t *SimpleChaincode) settlement_summaries(stub shim.ChaincodeStubInterface) ([]Settlement_Summary, error) {
+
+	refs, err := t.retrieve_payment_refs(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]*Settlement_Summary)
+	for _, ref := range refs.References {
+		p, found, err := t.retrieve_payment(stub, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errors.New("Payment " + ref + " is indexed but missing")
+		}
+		key := p.PayeePlan + "\x00" + p.PayerPlan
+		s, ok := totals[key]
+		if !ok {
+			s = &Settlement_Summary{LocalPlanCode: p.PayeePlan, RemotePlanCode: p.PayerPlan}
+			totals[key] = s
+		}
+		s.PaymentCount++
+		s.SettledAmount += p.Amount
+	}
+
+	keys := make([]string, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	summaries := make([]Settlement_Summary, 0, len(keys))
+	for _, key := range keys {
+		summaries = append(summaries, *totals[key])
+	}
+	return summaries, nil
+}
+
+//=================================================================================================================================
+//	 reconcile_plans - Returns the total settled between one Host and Home plan pair. A caller acting for a plan can
+//					   only reconcile pairs it is part of.
+//=================================================================================================================================
+func (t *SimpleChaincode) reconcile_plans(stub shim.ChaincodeStubInterface, localPlan string, remotePlan string, caller string, plan string) ([]byte, error) {
+
+	if caller != Host && caller != Home && caller != CFA {
+		return nil, errors.New("Permission Denied. " + caller + " can not reconcile payments")
+	}
+	if plan != "" && plan != localPlan && plan != remotePlan {
+		return nil, errors.New("Permission Denied. Plan " + plan + " can not reconcile other plans")
+	}
+
+	summaries, err := t.settlement_summaries(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Settlement_Summary{LocalPlanCode: localPlan, RemotePlanCode: remotePlan}
+	for _, s := range summaries {
+		if s.LocalPlanCode == localPlan && s.RemotePlanCode == remotePlan {
+			result = s
+		}
+	}
+	return json.Marshal(result)
+}
+
+//=================================================================================================================================
+//	 get_settlement_summary - Returns the settled totals for every Host and Home plan pair, or only the pairs including
+//							  the caller's plan.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_settlement_summary(stub shim.ChaincodeStubInterface, caller string, plan string) ([]byte, error) {
+
+	if caller != Host && caller != Home && caller != CFA {
+		return nil, errors.New("Permission Denied. " + caller + " can not reconcile payments")
+	}
+
+	summaries, err := t.settlement_summaries(stub)
+	if err != nil {
+		return nil, err
+	}
+	if plan == "" {
+		return json.Marshal(summaries)
+	}
+	own := []Settlement_Summary{}
+	for _, s := range summaries {
+		if s.LocalPlanCode == plan || s.RemotePlanCode == plan {
+			own = append(own, s)
+		}
+	}
+	return json.Marshal(own)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/privdata"
+)
+
+//==============================================================================================================================
+//	 Private collections - The PHI on a claim is kept out of the shared Claim record and sealed by the privdata package
+//						   under "Private_" + collection + "_" + ClaimID. The Claim only keeps a salted hash of each value.
+//==============================================================================================================================
+const COLLECTION_MEMBER = "memberPHI"
+const COLLECTION_CLINICAL = "clinicalPHI"
+const COLLECTION_DUPLICATE = "duplicateIndex"
+
+//==============================================================================================================================
+//	 privateCollections - The organisations allowed to read each collection. The CFA only settles payments and reads
+//						  neither. Queries resolve the caller with claim_role, so a reader must also act for one of the
+//						  plans the claim is routed to. The duplicateIndex collection has no fields or readers; its key
+//						  is passed by the Initiator when creating a claim to hash the duplicate index keys.
+//==============================================================================================================================
+var privateCollections = privdata.Collections{
+	{Name: COLLECTION_MEMBER, Readers: []string{Initiator, Host, Home}},
+	{Name: COLLECTION_CLINICAL, Readers: []string{Initiator, Host, Home}},
+	{Name: COLLECTION_DUPLICATE},
+}
+
+//==============================================================================================================================
+//	 private_fields - Returns the private fields of a claim. The procedure code of each claim line is private as well.
+//==============================================================================================================================
+func private_fields(c *Claim) []privdata.Field {
+
+	fields := []privdata.Field{
+		{Collection: COLLECTION_MEMBER, Name: "memberId", Value: &c.MemberID},
+		{Collection: COLLECTION_MEMBER, Name: "subscriberId", Value: &c.SubscriberID},
+		{Collection: COLLECTION_CLINICAL, Name: "diagCode", Value: &c.DiagCode},
+		{Collection: COLLECTION_CLINICAL, Name: "procedureCode", Value: &c.ProcedureCode},
+	}
+	for i := range c.Lines {
+		name := "lines." + strconv.Itoa(c.Lines[i].LineNumber) + ".procedureCode"
+		fields = append(fields, privdata.Field{Collection: COLLECTION_CLINICAL, Name: name, Value: &c.Lines[i].ProcedureCode})
+	}
+	return fields
+}
+
+//==============================================================================================================================
+//	 seal_private - Moves any private values set on a claim into their collections, replacing each with its hash on the
+//					claim.
+//==============================================================================================================================
+func (t *SimpleChaincode) seal_private(stub shim.ChaincodeStubInterface, c *Claim) error {
+
+	hashes := privdata.Hashes{Salt: c.PrivateSalt, Values: c.PrivateHashes}
+	err := privateCollections.Seal(stub, c.ClaimID, private_fields(c), &hashes)
+	if err != nil {
+		return err
+	}
+	c.PrivateSalt = hashes.Salt
+	c.PrivateHashes = hashes.Values
+	return nil
+}
+
+//==============================================================================================================================
+//	 reveal_private - Fills in the private values of a claim from the collections the caller may read and passed the
+//					  key for, clearing the rest.
+//==============================================================================================================================
+func (t *SimpleChaincode) reveal_private(stub shim.ChaincodeStubInterface, c *Claim, caller string) error {
+
+	return privateCollections.Reveal(stub, c.ClaimID, private_fields(c), caller)
+}
+
+//==============================================================================================================================
+//	 copy_private - Gives an adjustment claim the sealed values and hashes of the claim it adjusts.
+//==============================================================================================================================
+func (t *SimpleChaincode) copy_private(stub shim.ChaincodeStubInterface, c Claim, adj *Claim) error {
+
+	from := privdata.Hashes{Salt: c.PrivateSalt, Values: c.PrivateHashes}
+	hashes, err := privateCollections.Copy(stub, c.ClaimID, from, adj.ClaimID, private_fields(adj))
+	if err != nil {
+		return err
+	}
+	adj.PrivateSalt = hashes.Salt
+	adj.PrivateHashes = hashes.Values
+	return nil
+}
+
+//=================================================================================================================================
+//	 Private Collection Functions
+//=================================================================================================================================
+//	 register_collection_key - The Admin registers the hash of the key shared by the organisations that read a
+//							   collection. It can only be registered once.
+//=================================================================================================================================
+func (t *SimpleChaincode) register_collection_key(stub shim.ChaincodeStubInterface, caller string, collection string, keyHash string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Permission Denied. Only the Admin can register collection keys")
+	}
+	return nil, privateCollections.RegisterKey(stub, collection, keyHash)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	Provider - A billing provider. The provider's HomePlan is the local (Host) plan its claims are routed to. Claims
+//			   are only accepted from in network providers. Stored on the ledger under "Provider_" + ProviderID.
+//==============================================================================================================================
+type Provider struct {
+	ProviderID  string `json:"providerId"`
+	Name        string `json:"name"`
+	HomePlan    string `json:"homePlan"`
+	InNetwork   bool   `json:"inNetwork"`
+	ActiveStart string `json:"activeStart"`
+	ActiveEnd   string `json:"activeEnd"`
+}
+
+//==============================================================================================================================
+//	 valid_npi - Checks a provider id is a 10 digit NPI with a correct check digit. The check digit is the Luhn digit
+//				 of the first nine digits prefixed with the 80840 issuer code.
+//==============================================================================================================================
+func valid_npi(npi string) bool {
+
+	if len(npi) != 10 {
+		return false
+	}
+	sum := 24 // Luhn contribution of the 80840 prefix
+	for i := 8; i >= 0; i-- {
+		d := int(npi[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if (8-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	check := int(npi[9] - '0')
+	return check >= 0 && check <= 9 && (sum+check)%10 == 0
+}
+
+//==============================================================================================================================
+//	 retrieve_provider - Gets a Provider from the ledger. Returns false if the provider has not been registered.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_provider(stub shim.ChaincodeStubInterface, providerId string) (Provider, bool, error) {
+
+	var p Provider
+
+	bytes, err := stub.GetState("Provider_" + providerId)
+	if err != nil {
+		return p, false, errors.New("Unable to get provider " + providerId)
+	}
+	if bytes == nil {
+		return p, false, nil
+	}
+	err = json.Unmarshal(bytes, &p)
+	if err != nil {
+		return p, false, errors.New("Corrupt Provider record " + providerId)
+	}
+	return p, true, nil
+}
+
+//==============================================================================================================================
+//	 active_provider - Returns the provider if it is registered, in network and active on the service date.
+//==============================================================================================================================
+func (t *SimpleChaincode) active_provider(stub shim.ChaincodeStubInterface, providerId string, serviceDate string) (Provider, error) {
+
+	date, err := parse_date(serviceDate)
+	if err != nil {
+		return Provider{}, err
+	}
+	p, found, err := t.retrieve_provider(stub, providerId)
+	if err != nil {
+		return p, err
+	}
+	if !found {
+		return p, errors.New("Provider " + providerId + " is not registered")
+	}
+	if !p.InNetwork {
+		return p, errors.New("Provider " + providerId + " is not in network")
+	}
+	active := Coverage{PlanCode: p.HomePlan, Start: p.ActiveStart, End: p.ActiveEnd}
+	if !active.covers(date) {
+		return p, errors.New("Provider " + providerId + " was not active on " + serviceDate)
+	}
+	return p, nil
+}
+
+//=================================================================================================================================
+//	 Provider Functions
+//=================================================================================================================================
+//	 register_provider - The Admin adds a provider or replaces its details. An empty end date leaves the provider active.
+//=================================================================================================================================
+func (t *SimpleChaincode) register_provider(stub shim.ChaincodeStubInterface, caller string, providerId string, name string, homePlan string, inNetwork string, start string, end string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can maintain providers")
+	}
+	if !valid_npi(providerId) {
+		return nil, errors.New("Invalid provider id " + providerId + ", expecting a 10 digit NPI")
+	}
+	if homePlan == "" {
+		return nil, errors.New("A home plan code is required")
+	}
+	network, err := strconv.ParseBool(inNetwork)
+	if err != nil {
+		return nil, errors.New("Invalid in network flag " + inNetwork)
+	}
+	startDate, err := parse_date(start)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Provider{
+		ProviderID:  providerId,
+		Name:        name,
+		HomePlan:    homePlan,
+		InNetwork:   network,
+		ActiveStart: startDate.Format("2006-01-02"),
+	}
+	if end != "" {
+		endDate, err := parse_date(end)
+		if err != nil {
+			return nil, err
+		}
+		if endDate.Before(startDate) {
+			return nil, errors.New("Provider can not be deactivated before it is active")
+		}
+		p.ActiveEnd = endDate.Format("2006-01-02")
+	}
+
+	bytes, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.New("Error converting Provider record")
+	}
+	err = stub.PutState("Provider_"+providerId, bytes)
+	if err != nil {
+		return nil, errors.New("Error storing Provider record")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_provider - Returns a provider's details.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_provider(stub shim.ChaincodeStubInterface, providerId string) ([]byte, error) {
+
+	p, found, err := t.retrieve_provider(stub, providerId)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("Provider " + providerId + " is not registered")
+	}
+	return json.Marshal(p)
+}
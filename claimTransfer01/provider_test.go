@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestValidNPI(t *testing.T) {
+
+	cases := []struct {
+		npi   string
+		valid bool
+	}{
+		{"1234567893", true},
+		{"1245319599", true},
+		{"1003000126", true},
+		{"1234567890", false},
+		{"1234567894", false},
+		{"1245319598", false},
+		{"123456789", false},
+		{"12345678931", false},
+		{"", false},
+		{"12345678a3", false},
+		{"123456789X", false},
+		{"1234 67893", false},
+		{"-234567893", false},
+	}
+	for _, c := range cases {
+		if valid_npi(c.npi) != c.valid {
+			t.Errorf("valid_npi(%q) = %v, expected %v", c.npi, !c.valid, c.valid)
+		}
+	}
+}
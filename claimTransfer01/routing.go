@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Plan roles - The roles a participating plan can take in the claim workflow. Each maps to the participant type the
+//				  workflow functions check for.
+//==============================================================================================================================
+const ROLE_INITIATOR = "INITIATOR"
+const ROLE_HOST = "HOST"
+const ROLE_HOME = "HOME"
+const ROLE_CFA = "CFA"
+
+var roleParticipants = map[string]string{
+	ROLE_INITIATOR: Initiator,
+	ROLE_HOST:      Host,
+	ROLE_HOME:      Home,
+	ROLE_CFA:       CFA,
+}
+
+//==============================================================================================================================
+//	Plan - A participating plan organisation and the roles it may take. Stored on the ledger under "Plan_" + PlanCode.
+//==============================================================================================================================
+type Plan struct {
+	PlanCode string   `json:"planCode"`
+	Name     string   `json:"name"`
+	Roles    []string `json:"roles"`
+}
+
+//==============================================================================================================================
+//	Plan_Holder - Holds the PlanCodes of every registered plan. Stored on the ledger under "PlanCodes".
+//==============================================================================================================================
+type Plan_Holder struct {
+	PlanCodes []string `json:"planCodes"`
+}
+
+//==============================================================================================================================
+//	Participant - A user acting for a plan in one of its roles. Stored on the ledger under "Participant_" + UserID.
+//==============================================================================================================================
+type Participant struct {
+	UserID   string `json:"userId"`
+	PlanCode string `json:"planCode"`
+	Role     string `json:"role"`
+}
+
+//==============================================================================================================================
+//	 has_role - Returns true if the plan may take the role passed in.
+//==============================================================================================================================
+func (p Plan) has_role(role string) bool {
+
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+//==============================================================================================================================
+//	 retrieve_plan - Gets a Plan from the ledger. Returns false if the plan has not been registered.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_plan(stub shim.ChaincodeStubInterface, planCode string) (Plan, bool, error) {
+
+	var p Plan
+
+	bytes, err := stub.GetState("Plan_" + planCode)
+	if err != nil {
+		return p, false, errors.New("Unable to get plan " + planCode)
+	}
+	if bytes == nil {
+		return p, false, nil
+	}
+	err = json.Unmarshal(bytes, &p)
+	if err != nil {
+		return p, false, errors.New("Corrupt Plan record " + planCode)
+	}
+	return p, true, nil
+}
+
+//==============================================================================================================================
+//	 retrieve_plan_codes - Gets the index of registered PlanCodes from the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_plan_codes(stub shim.ChaincodeStubInterface) (Plan_Holder, error) {
+
+	var planCodes Plan_Holder
+
+	bytes, err := stub.GetState("PlanCodes")
+	if err != nil {
+		return planCodes, errors.New("Unable to get PlanCodes")
+	}
+	if bytes == nil {
+		return planCodes, nil
+	}
+	err = json.Unmarshal(bytes, &planCodes)
+	if err != nil {
+		return planCodes, errors.New("Corrupt Plan_Holder record")
+	}
+	return planCodes, nil
+}
+
+//==============================================================================================================================
+//	 retrieve_participant - Gets a Participant from the ledger. Returns false if the user has not been registered.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_participant(stub shim.ChaincodeStubInterface, userId string) (Participant, bool, error) {
+
+	var p Participant
+
+	bytes, err := stub.GetState("Participant_" + userId)
+	if err != nil {
+		return p, false, errors.New("Unable to get participant " + userId)
+	}
+	if bytes == nil {
+		return p, false, nil
+	}
+	err = json.Unmarshal(bytes, &p)
+	if err != nil {
+		return p, false, errors.New("Corrupt Participant record " + userId)
+	}
+	return p, true, nil
+}
+
+//==============================================================================================================================
+//	 caller_role - Returns the participant type the caller acts as and the plan they act for. The caller must be the
+//				   user whose ecert signed the transaction and, other than the Admin, a registered participant. The
+//				   Admin acts for no particular plan and must also hold the Admin role in their ecert. A CFA settles
+//				   between any pair of plans, so it acts for no particular plan either.
+//==============================================================================================================================
+func (t *SimpleChaincode) caller_role(stub shim.ChaincodeStubInterface, caller string) (string, string, error) {
+
+	err := t.verify_caller(stub, caller)
+	if err != nil {
+		return "", "", err
+	}
+	if caller == Admin {
+		err = t.verify_role(stub, Admin)
+		if err != nil {
+			return "", "", err
+		}
+		return Admin, "", nil
+	}
+	p, found, err := t.retrieve_participant(stub, caller)
+	if err != nil {
+		return "", "", err
+	}
+	if !found {
+		return "", "", errors.New("Permission Denied. " + caller + " is not a registered participant")
+	}
+	if p.Role == ROLE_CFA {
+		return CFA, "", nil
+	}
+	return roleParticipants[p.Role], p.PlanCode, nil
+}
+
+//==============================================================================================================================
+//	 claim_role - Returns the participant type the caller acts as on a claim. Host and Initiator participants must
+//				  belong to the claim's Host plan (LocalPlanCode) and Home participants to its Home plan
+//				  (RemotePlanCode). The Admin and CFA act on every claim.
+//==============================================================================================================================
+func (t *SimpleChaincode) claim_role(stub shim.ChaincodeStubInterface, caller string, c Claim) (string, error) {
+
+	role, plan, err := t.caller_role(stub, caller)
+	if err != nil {
+		return "", err
+	}
+	if plan == "" {
+		return role, nil
+	}
+	switch role {
+	case Initiator, Host:
+		if plan == c.LocalPlanCode {
+			return role, nil
+		}
+	case Home:
+		if plan == c.RemotePlanCode {
+			return role, nil
+		}
+	}
+	return "", errors.New("Permission Denied. " + caller + " is not routed to claim " + c.ClaimID)
+}
+
+//==============================================================================================================================
+//	 check_routing - A new claim's Host plan must be registered in the HOST role and its Home plan in the HOME role.
+//==============================================================================================================================
+func (t *SimpleChaincode) check_routing(stub shim.ChaincodeStubInterface, c Claim) error {
+
+	routes := []struct{ planCode, role string }{{c.LocalPlanCode, ROLE_HOST}, {c.RemotePlanCode, ROLE_HOME}}
+	for _, r := range routes {
+		p, found, err := t.retrieve_plan(stub, r.planCode)
+		if err != nil {
+			return err
+		}
+		if !found || !p.has_role(r.role) {
+			return errors.New("Plan " + r.planCode + " is not registered as a " + strings.ToLower(r.role) + " plan")
+		}
+	}
+	return nil
+}
+
+//=================================================================================================================================
+//	 Routing Functions
+//=================================================================================================================================
+//	 register_plan - The Admin registers a participating plan or replaces its details. roles is a comma separated list
+//					 of the roles the plan takes, e.g. "HOST,HOME".
+//=================================================================================================================================
+func (t *SimpleChaincode) register_plan(stub shim.ChaincodeStubInterface, caller string, planCode string, name string, roles string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can register plans")
+	}
+	if planCode == "" || planCode == "UNDEFINED" {
+		return nil, errors.New("A plan code is required")
+	}
+
+	p := Plan{PlanCode: planCode, Name: name}
+	for _, role := range strings.Split(roles, ",") {
+		role = strings.ToUpper(strings.TrimSpace(role))
+		if _, ok := roleParticipants[role]; !ok {
+			return nil, errors.New("Unknown plan role " + role)
+		}
+		if !p.has_role(role) {
+			p.Roles = append(p.Roles, role)
+		}
+	}
+
+	_, found, err := t.retrieve_plan(stub, planCode)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.New("Error converting Plan record")
+	}
+	err = stub.PutState("Plan_"+planCode, bytes)
+	if err != nil {
+		return nil, errors.New("Error storing Plan record")
+	}
+	if found {
+		return nil, nil
+	}
+
+	planCodes, err := t.retrieve_plan_codes(stub)
+	if err != nil {
+		return nil, err
+	}
+	planCodes.PlanCodes = append(planCodes.PlanCodes, planCode)
+
+	bytes, err = json.Marshal(planCodes)
+	if err != nil {
+		return nil, errors.New("Error creating Plan_Holder record")
+	}
+	err = stub.PutState("PlanCodes", bytes)
+	if err != nil {
+		return nil, errors.New("Unable to put the state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 register_participant - The Admin registers a user to act for a plan in one of the plan's roles, replacing any
+//							earlier registration of the user.
+//=================================================================================================================================
+func (t *SimpleChaincode) register_participant(stub shim.ChaincodeStubInterface, caller string, userId string, planCode string, role string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can register participants")
+	}
+	if userId == "" || userId == Admin {
+		return nil, errors.New("Invalid participant user " + userId)
+	}
+	role = strings.ToUpper(role)
+	plan, found, err := t.retrieve_plan(stub, planCode)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("Plan " + planCode + " is not registered")
+	}
+	if !plan.has_role(role) {
+		return nil, errors.New("Plan " + planCode + " does not take the " + role + " role")
+	}
+
+	bytes, err := json.Marshal(Participant{UserID: userId, PlanCode: planCode, Role: role})
+	if err != nil {
+		return nil, errors.New("Error converting Participant record")
+	}
+	err = stub.PutState("Participant_"+userId, bytes)
+	if err != nil {
+		return nil, errors.New("Error storing Participant record")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_plans - Returns every registered plan.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_plans(stub shim.ChaincodeStubInterface) ([]byte, error) {
+
+	planCodes, err := t.retrieve_plan_codes(stub)
+	if err != nil {
+		return nil, err
+	}
+	plans := []Plan{}
+	for _, code := range planCodes.PlanCodes {
+		p, found, err := t.retrieve_plan(stub, code)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, errors.New("Plan " + code + " is indexed but missing")
+		}
+		plans = append(plans, p)
+	}
+	return json.Marshal(plans)
+}
+
+//=================================================================================================================================
+//	 get_participant - Returns the plan and role a user is registered for.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_participant(stub shim.ChaincodeStubInterface, userId string) ([]byte, error) {
+
+	p, found, err := t.retrieve_participant(stub, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("Participant " + userId + " is not registered")
+	}
+	return json.Marshal(p)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	StateEntry - Records a claim entering one of the STATE_ stages and the timestamp of the transaction that moved it.
+//==============================================================================================================================
+type StateEntry struct {
+	State   string `json:"state"`
+	Entered string `json:"entered"`
+}
+
+//==============================================================================================================================
+//	SLA_Config - Number of days the party holding a claim has to act on it in each STATE_ stage. Stages with no entry
+//				 have no deadline. Stored on the ledger under "SLA_Config".
+//==============================================================================================================================
+type SLA_Config struct {
+	Days map[string]int `json:"days"`
+}
+
+//==============================================================================================================================
+//	Claim_Holder - Holds the ClaimIDs of every claim created. Used as an index when querying all claims.
+//==============================================================================================================================
+type Claim_Holder struct {
+	ClaimIDs []string `json:"claimIds"`
+}
+
+//==============================================================================================================================
+//	Overdue_Claim - A claim that has been held in its current stage for longer than the stage's SLA.
+//==============================================================================================================================
+type Overdue_Claim struct {
+	ClaimID      string `json:"claimId"`
+	State        string `json:"state"`
+	Owner        string `json:"owner"`
+	Entered      string `json:"entered"`
+	Deadline     string `json:"deadline"`
+	OverdueHours int64  `json:"overdueHours"`
+	OverdueDays  int64  `json:"overdueDays"`
+}
+
+//==============================================================================================================================
+//	 enter_state - Moves a claim into a new stage, recording the transaction timestamp on the claim. The caller still
+//				   needs to save the claim.
+//==============================================================================================================================
+func (t *SimpleChaincode) enter_state(stub shim.ChaincodeStubInterface, c *Claim, state string) error {
+
+	entered, err := tx_timestamp(stub)
+	if err != nil {
+		return err
+	}
+	c.States = append(c.States, StateEntry{State: state, Entered: entered})
+	return nil
+}
+
+//==============================================================================================================================
+//	 current_state - Returns the latest stage entry of a claim. Claims recorded before stages were tracked return false.
+//==============================================================================================================================
+func current_state(c Claim) (StateEntry, bool) {
+
+	if len(c.States) == 0 {
+		return StateEntry{}, false
+	}
+	return c.States[len(c.States)-1], true
+}
+
+//==============================================================================================================================
+//	 retrieve_claim_ids - Gets the index of ClaimIDs from the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_claim_ids(stub shim.ChaincodeStubInterface) (Claim_Holder, error) {
+
+	var claimIDs Claim_Holder
+
+	bytes, err := stub.GetState("ClaimIDs")
+	if err != nil {
+		return claimIDs, errors.New("Unable to get ClaimIDs")
+	}
+	if bytes == nil {
+		return claimIDs, nil
+	}
+	err = json.Unmarshal(bytes, &claimIDs)
+	if err != nil {
+		return claimIDs, errors.New("Corrupt Claim_Holder record")
+	}
+	return claimIDs, nil
+}
+
+//==============================================================================================================================
+//	 index_claim - Adds a new ClaimID to the index of all claims.
+//==============================================================================================================================
+func (t *SimpleChaincode) index_claim(stub shim.ChaincodeStubInterface, claimId string) error {
+
+	claimIDs, err := t.retrieve_claim_ids(stub)
+	if err != nil {
+		return err
+	}
+	claimIDs.ClaimIDs = append(claimIDs.ClaimIDs, claimId)
+
+	bytes, err := json.Marshal(claimIDs)
+	if err != nil {
+		return errors.New("Error creating Claim_Holder record")
+	}
+	err = stub.PutState("ClaimIDs", bytes)
+	if err != nil {
+		return errors.New("Unable to put the state")
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 retrieve_sla - Gets the SLA configuration from the ledger. No configuration means no deadlines.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_sla(stub shim.ChaincodeStubInterface) (SLA_Config, error) {
+
+	var sla SLA_Config
+
+	bytes, err := stub.GetState("SLA_Config")
+	if err != nil {
+		return sla, errors.New("Unable to get SLA_Config")
+	}
+	if bytes != nil {
+		err = json.Unmarshal(bytes, &sla)
+		if err != nil {
+			return sla, errors.New("Corrupt SLA_Config record")
+		}
+	}
+	if sla.Days == nil {
+		sla.Days = make(map[string]int)
+	}
+	return sla, nil
+}
+
+//=================================================================================================================================
+//	 SLA Functions
+//=================================================================================================================================
+//	 set_sla - The Admin sets the number of days allowed in a stage. Zero days removes the deadline for the stage.
+//=================================================================================================================================
+func (t *SimpleChaincode) set_sla(stub shim.ChaincodeStubInterface, caller string, state string, days string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can set SLA deadlines")
+	}
+	switch state {
+	case STATE_INITIATE, STATE_HOST, STATE_HOME, STATE_HOME_HOST, STATE_CFA, STATE_DENIED:
+	default:
+		return nil, errors.New("Unknown state " + state)
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 0 {
+		return nil, errors.New("Invalid number of days " + days)
+	}
+
+	sla, err := t.retrieve_sla(stub)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		delete(sla.Days, state)
+	} else {
+		sla.Days[state] = n
+	}
+
+	bytes, err := json.Marshal(sla)
+	if err != nil {
+		return nil, errors.New("Error converting SLA_Config record")
+	}
+	err = stub.PutState("SLA_Config", bytes)
+	if err != nil {
+		return nil, errors.New("Unable to put the state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_sla - Returns the SLA configuration.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_sla(stub shim.ChaincodeStubInterface) ([]byte, error) {
+
+	sla, err := t.retrieve_sla(stub)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sla)
+}
+
+//=================================================================================================================================
+//	 overdue_claims - Returns every claim held in a stage past its SLA deadline, most overdue first. Claims no party has
+//					  to act on are skipped. Lateness is measured at asOf (RFC3339) when it is given, otherwise at the
+//					  transaction timestamp. A caller acting for a plan only sees the claims routed to that plan.
+//=================================================================================================================================
+func (t *SimpleChaincode) overdue_claims(stub shim.ChaincodeStubInterface, caller string, plan string, asOf string) ([]byte, error) {
+
+	if caller != Admin && caller != Host && caller != Home {
+		return nil, errors.New("Permission Denied. " + caller + " can not view overdue claims")
+	}
+
+	var now time.Time
+	var err error
+	if asOf != "" {
+		now, err = time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			return nil, errors.New("Invalid asOf timestamp " + asOf)
+		}
+	} else {
+		now, err = tx_time(stub)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sla, err := t.retrieve_sla(stub)
+	if err != nil {
+		return nil, err
+	}
+	claimIDs, err := t.retrieve_claim_ids(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	overdue := []Overdue_Claim{}
+	for _, id := range claimIDs.ClaimIDs {
+		c, err := t.retrieve_claim(stub, id)
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := current_state(c)
+		if !ok || is_terminal(c) {
+			continue
+		}
+		if plan != "" && plan != c.LocalPlanCode && plan != c.RemotePlanCode {
+			continue
+		}
+		days, ok := sla.Days[entry.State]
+		if !ok {
+			continue
+		}
+		entered, err := time.Parse(time.RFC3339, entry.Entered)
+		if err != nil {
+			return nil, fmt.Errorf("Corrupt state entry on claim %s", id)
+		}
+		deadline := entered.AddDate(0, 0, days)
+		if !now.After(deadline) {
+			continue
+		}
+		late := now.Sub(deadline)
+		overdue = append(overdue, Overdue_Claim{
+			ClaimID:      c.ClaimID,
+			State:        entry.State,
+			Owner:        c.Owner,
+			Entered:      entry.Entered,
+			Deadline:     deadline.Format(time.RFC3339),
+			OverdueHours: int64(late / time.Hour),
+			OverdueDays:  int64(late / (24 * time.Hour)),
+		})
+	}
+
+	sort.SliceStable(overdue, func(i, j int) bool { return overdue[i].Deadline < overdue[j].Deadline })
+
+	return json.Marshal(overdue)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+//==============================================================================================================================
+//	 Visibility rules - The Claim JSON fields each participant type is entitled to see. The Initiator and Host work the
+//						claim and see everything, the Home plan sees what it needs to set the member's cost share and
+//						the CFA only sees the fields needed to make the payment.
+//==============================================================================================================================
+var homeFields = []string{
+	"claimId", "serviceDate", "admissionDate", "providerId", "memberId", "subscriberId", "diagCode", "procedureCode",
+	"procedureDate", "billCode", "SrvcUnitNbr", "revenueCode", "revenueDesc", "unitOfService", "chargedAmount",
+	"nonCovAmount", "approvedAmount", "localPlanCode", "remotePlanCode", "costShare",
+	"adjustmentFlag", "owner", "finalApprovedAmount", "claimStatus", "paymentReference", "statusReason", "statusNote",
+	"originalClaimId", "adjustmentType", "adjustmentIds", "states", "duplicateStatus", "duplicateOf", "lines",
+	"privateSalt", "privateHashes",
+}
+
+var cfaFields = []string{
+	"claimId", "localPlanCode", "remotePlanCode", "approvedAmount", "costShare", "finalApprovedAmount",
+	"paymentMethod", "paymentReference", "owner", "claimStatus", "originalClaimId", "adjustmentType", "adjustmentIds",
+}
+
+//==============================================================================================================================
+//	 visible_fields - Returns the set of JSON field names the caller may see. A nil set means every field is visible.
+//==============================================================================================================================
+func visible_fields(caller string) (map[string]bool, error) {
+
+	var fields []string
+
+	switch caller {
+	case Initiator, Host:
+		return nil, nil
+	case Home:
+		fields = homeFields
+	case CFA:
+		fields = cfaFields
+	default:
+		return nil, errors.New("Permission Denied. " + caller + " can not view claims")
+	}
+
+	allowed := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		allowed[f] = true
+	}
+	return allowed, nil
+}
+
+//==============================================================================================================================
+//	 project_claim - Converts a Claim to JSON containing only the fields the caller may see.
+//==============================================================================================================================
+func project_claim(c Claim, caller string) ([]byte, error) {
+
+	allowed, err := visible_fields(caller)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.New("Error converting Claim record")
+	}
+	if allowed == nil {
+		return bytes, nil
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(bytes, &fields)
+	if err != nil {
+		return nil, errors.New("Error converting Claim record")
+	}
+	for name := range fields {
+		if !allowed[name] {
+			delete(fields, name)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+//==============================================================================================================================
+//	 project_history - Removes the changes to fields the caller may not see from a claim's audit entries.
+//==============================================================================================================================
+func project_history(entries []AuditEntry, caller string) ([]AuditEntry, error) {
+
+	allowed, err := visible_fields(caller)
+	if err != nil {
+		return nil, err
+	}
+	if allowed == nil {
+		return entries, nil
+	}
+
+	projected := make([]AuditEntry, 0, len(entries))
+	for _, e := range entries {
+		changes := []FieldChange{}
+		for _, change := range e.Changes {
+			if allowed[change.Field] {
+				changes = append(changes, change)
+			}
+		}
+		e.Changes = changes
+		projected = append(projected, e)
+	}
+	return projected, nil
+}
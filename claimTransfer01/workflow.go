@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 Claim status values - Set on Claim.ClaimStatus as the claim moves through adjudication.
+//==============================================================================================================================
+const STATUS_INITIATED = "INITIATED"
+const STATUS_HOST_APPROVED = "HOSTAPPROVED"
+const STATUS_ADJUDICATED = "ADJUDICATED"
+const STATUS_PAYMENT_COMPLETE = "PAYMENTCOMPLETE"
+const STATUS_DENIED = "DENIED"
+const STATUS_RETURNED = "RETURNED"
+const STATUS_RESUBMITTED = "RESUBMITTED"
+const STATUS_APPEALED = "APPEALED"
+
+//==============================================================================================================================
+//	 Transition - The claim statuses an invoke function may be applied from. When ByStageOwner is set the caller must
+//				  also be the party that holds the claim's current STATE_ stage, so a role can only act on a claim once
+//				  it has been handed the claim.
+//==============================================================================================================================
+type Transition struct {
+	From         []string
+	ByStageOwner bool
+}
+
+//==============================================================================================================================
+//	 Transition rules - The transition of each invoke function. Functions not listed here do not act on an existing
+//						claim. Role checks are made by the functions themselves.
+//==============================================================================================================================
+var transitions = map[string]Transition{
+	"add_claim_line":         {From: []string{STATUS_INITIATED, STATUS_RETURNED}},
+	"update_claim_line":      {From: []string{STATUS_INITIATED, STATUS_RETURNED}},
+	"approve_claim_line":     {From: []string{STATUS_INITIATED, STATUS_RESUBMITTED, STATUS_APPEALED}},
+	"transfer_to_host":       {From: []string{STATUS_INITIATED, STATUS_RESUBMITTED, STATUS_APPEALED}},
+	"update_by_host":         {From: []string{STATUS_INITIATED, STATUS_RESUBMITTED, STATUS_APPEALED, STATUS_HOST_APPROVED}},
+	"transfer_to_home":       {From: []string{STATUS_HOST_APPROVED}},
+	"update_by_home":         {From: []string{STATUS_HOST_APPROVED, STATUS_ADJUDICATED}},
+	"transfer_to_hostByHome": {From: []string{STATUS_ADJUDICATED}},
+	"update_by_hostForCFA":   {From: []string{STATUS_ADJUDICATED, STATUS_PAYMENT_COMPLETE}},
+	"transfer_to_cfa":        {From: []string{STATUS_PAYMENT_COMPLETE}},
+	"deny_claim":             {From: []string{STATUS_INITIATED, STATUS_RESUBMITTED, STATUS_APPEALED, STATUS_HOST_APPROVED}, ByStageOwner: true},
+	"return_to_initiator":    {From: []string{STATUS_INITIATED, STATUS_RESUBMITTED, STATUS_APPEALED, STATUS_HOST_APPROVED}, ByStageOwner: true},
+	"resubmit_claim":         {From: []string{STATUS_RETURNED}},
+	"appeal_claim":           {From: []string{STATUS_DENIED}},
+	"adjust_claim":           {From: []string{STATUS_PAYMENT_COMPLETE}},
+	"void_claim":             {From: []string{STATUS_PAYMENT_COMPLETE}},
+}
+
+//==============================================================================================================================
+//	 Stage owners - The party that holds a claim in each STATE_ stage.
+//==============================================================================================================================
+var stageOwners = map[string]string{
+	STATE_INITIATE:  Initiator,
+	STATE_HOST:      Host,
+	STATE_HOME:      Home,
+	STATE_HOME_HOST: Host,
+	STATE_CFA:       CFA,
+}
+
+//==============================================================================================================================
+//	 Denial reason codes - The reasons a Host or Home plan may give for denying a claim.
+//==============================================================================================================================
+var denialReasons = map[string]string{
+	"NOTCOVERED":     "Service not covered by the member's benefit plan",
+	"NOTELIGIBLE":    "Member not eligible on the date of service",
+	"DUPLICATE":      "Duplicate of a claim already submitted",
+	"NOTNECESSARY":   "Service not medically necessary",
+	"NOAUTH":         "Required prior authorization not obtained",
+	"TIMELYFILING":   "Claim submitted after the filing limit",
+	"INVALIDCODING":  "Diagnosis or procedure coding is invalid",
+	"PROVIDERDENIED": "Provider not eligible to bill the service",
+}
+
+//==============================================================================================================================
+//	 is_terminal - Returns true once no party has to act on the claim: it has been paid or voided, or denied after its
+//				   one appeal.
+//==============================================================================================================================
+func is_terminal(c Claim) bool {
+
+	switch c.ClaimStatus {
+	case STATUS_PAYMENT_COMPLETE, STATUS_VOIDED:
+		return true
+	case STATUS_DENIED:
+		return c.AppealCount > 0
+	}
+	return false
+}
+
+//==============================================================================================================================
+//	 stage_owner - Returns the party holding the claim in its current stage. Claims recorded before stages were tracked
+//				   fall back to the claim's Owner.
+//==============================================================================================================================
+func stage_owner(c Claim) string {
+
+	entry, ok := current_state(c)
+	if !ok {
+		return c.Owner
+	}
+	return stageOwners[entry.State]
+}
+
+//==============================================================================================================================
+//	 check_transition - Returns an error if the caller can not apply the function to the claim in its current status
+//						and stage.
+//==============================================================================================================================
+func check_transition(function string, c Claim, caller string) error {
+
+	rule, ok := transitions[function]
+	if !ok {
+		return nil
+	}
+	allowed := false
+	for _, status := range rule.From {
+		if c.ClaimStatus == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%s is not allowed on claim %s with status %s", function, c.ClaimID, c.ClaimStatus)
+	}
+	if rule.ByStageOwner && caller != stage_owner(c) {
+		return fmt.Errorf("Permission Denied. %s does not hold claim %s in its current stage", caller, c.ClaimID)
+	}
+	return nil
+}
+
+//=================================================================================================================================
+//	 Rejection Functions
+//=================================================================================================================================
+//	 deny_claim - The Host, or the Home plan once the claim has been transferred to it, denies the claim with a reason
+//				  code. The claim goes back to the Initiator who may appeal it once.
+//=================================================================================================================================
+func (t *SimpleChaincode) deny_claim(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, reasonCode string, note string, storedUser string) ([]byte, error) {
+
+	if caller != Host && caller != Home {
+		return nil, errors.New("Permission Denied. " + caller + " can not deny the claim")
+	}
+	_, ok := denialReasons[reasonCode]
+	if !ok {
+		return nil, errors.New("Unknown denial reason code " + reasonCode)
+	}
+
+	c.Owner = Initiator
+	c.ClaimStatus = STATUS_DENIED
+	c.StatusReason = reasonCode
+	c.StatusNote = note
+
+	err := t.enter_state(stub, &c, STATE_DENIED)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil // We are Done
+
+}
+
+//=================================================================================================================================
+//	 return_to_initiator - The Host or Home plan holding the claim sends it back to the Initiator to be corrected.
+//=================================================================================================================================
+func (t *SimpleChaincode) return_to_initiator(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, note string, storedUser string) ([]byte, error) {
+
+	if caller != Host && caller != Home {
+		return nil, errors.New("Permission Denied. " + caller + " can not return the claim")
+	}
+
+	c.Owner = Initiator
+	c.ClaimStatus = STATUS_RETURNED
+	c.StatusReason = "CORRECTION"
+	c.StatusNote = note
+
+	err := t.enter_state(stub, &c, STATE_INITIATE)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil // We are Done
+
+}
+
+//=================================================================================================================================
+//	 resubmit_claim - The Initiator resubmits a corrected claim. It then goes to the Host again with transfer_to_host.
+//=================================================================================================================================
+func (t *SimpleChaincode) resubmit_claim(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, storedUser string) ([]byte, error) {
+
+	if caller != Initiator {
+		return nil, errors.New("Only the Initiator can resubmit the claim")
+	}
+
+	c.ClaimStatus = STATUS_RESUBMITTED
+	c.StatusReason = ""
+	c.StatusNote = ""
+
+	_, err := t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil // We are Done
+
+}
+
+//=================================================================================================================================
+//	 appeal_claim - The Initiator appeals a denial. The claim goes straight back to the Host to be reconsidered.
+//=================================================================================================================================
+func (t *SimpleChaincode) appeal_claim(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, note string, storedUser string) ([]byte, error) {
+
+	if caller != Initiator {
+		return nil, errors.New("Only the Initiator can appeal the claim")
+	}
+	if c.AppealCount > 0 {
+		return nil, errors.New("Claim " + claimId + " has already been appealed")
+	}
+
+	c.Owner = Host
+	c.ClaimStatus = STATUS_APPEALED
+	c.StatusNote = note
+	c.AppealCount++
+
+	err := t.enter_state(stub, &c, STATE_HOST)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.save_changes(stub, c) // Write new state
+
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil // We are Done
+
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+
+	staged := func(status string, states ...string) Claim {
+		c := Claim{ClaimID: "C1", ClaimStatus: status}
+		for _, s := range states {
+			c.States = append(c.States, StateEntry{State: s})
+		}
+		return c
+	}
+
+	cases := []struct {
+		name     string
+		function string
+		c        Claim
+		caller   string
+		valid    bool
+	}{
+		{"unlisted function", "get_claim_details", staged(STATUS_DENIED), CFA, true},
+		{"transfer from initiated", "transfer_to_host", staged(STATUS_INITIATED, STATE_INITIATE), Host, true},
+		{"transfer from denied", "transfer_to_host", staged(STATUS_DENIED, STATE_DENIED), Host, false},
+		{"host denies held claim", "deny_claim", staged(STATUS_INITIATED, STATE_INITIATE, STATE_HOST), Host, true},
+		{"host denies before transfer", "deny_claim", staged(STATUS_INITIATED, STATE_INITIATE), Host, false},
+		{"home denies before transfer", "deny_claim", staged(STATUS_HOST_APPROVED, STATE_INITIATE, STATE_HOST), Home, false},
+		{"home denies held claim", "deny_claim", staged(STATUS_HOST_APPROVED, STATE_INITIATE, STATE_HOST, STATE_HOME), Home, true},
+		{"host denies claim held by home", "deny_claim", staged(STATUS_HOST_APPROVED, STATE_INITIATE, STATE_HOST, STATE_HOME), Host, false},
+		{"home returns before transfer", "return_to_initiator", staged(STATUS_HOST_APPROVED, STATE_INITIATE, STATE_HOST), Home, false},
+		{"untracked stage uses owner", "deny_claim", Claim{ClaimID: "C1", ClaimStatus: STATUS_INITIATED, Owner: Host}, Host, true},
+	}
+	for _, tc := range cases {
+		err := check_transition(tc.function, tc.c, tc.caller)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
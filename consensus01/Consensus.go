@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/router"
 )
 
 var logger = shim.NewLogger("CLDChaincode")
@@ -19,6 +20,8 @@ const Initiator = "user_type1_0"
 const Host = "user_type2_0"
 const Home = "user_type8_0"
 const CFA = "user_type4_0"
+const Admin = "admin"
+const Arbiter = "arbiter"
 
 //==============================================================================================================================
 //	 Status types - Claim Approval lifecycle is broken down into 5 statuses, this is part of the business logic to determine what can
@@ -42,81 +45,58 @@ type SimpleChaincode struct {
 //			  that element when reading a JSON object into the struct e.g. JSON make -> Struct Make.
 //==============================================================================================================================
 type Claim struct {
-	ClaimID       string `json:"claimId"`
-	ServiceDate   string `json:"serviceDate"`
-	AdmissionDate string `json:"admissionDate"`
-	ProviderID    string `json:"providerId"`
-	MemberID      string `json:"memberId"`
-	SubscriberID  string `json:"subscriberId"`
-	DiagCode      string `json:"diagCode"`
-	ProcedureCode string `json:"procedureCode"`
-	ProcedureDate string `json:"procedureDate"`
-	BillCode      string `json:"billCode"`
-	SrvcUnitNbr   string `json:"SrvcUnitNbr"`
-	RevenueCode   string `json:"revenueCode"`
-	RevenueDesc   string `json:"revenueDesc"`
-	AdmsnHourCode string `json:"admsnHourCode"`
-	AdmsnTypeCode string `json:"admsnTypeCode"`
-	AdmsnSrvcCode string `json:"admsnSrvcCode"`
-	UnitOfService string `json:"unitOfService"`
-	ChargedAmount string `json:"chargedAmount"`
-	NonCovAmount  string `json:"nonCovAmount"`
-	Owner         string `json:"owner"`
-	ApprovedAmt   string `json:"approvedAmt"`
-	UnpaidAmt     string `json:"unpaidAmt"`
-	CnsnsNote     string `json:"cnsnsNote"`
-	CnsnsStatus   string `json:"cnsnsStatus"`
+	ClaimID       string            `json:"claimId"`
+	ServiceDate   string            `json:"serviceDate"`
+	AdmissionDate string            `json:"admissionDate"`
+	ProviderID    string            `json:"providerId"`
+	MemberID      string            `json:"memberId"`
+	SubscriberID  string            `json:"subscriberId"`
+	DiagCode      string            `json:"diagCode"`
+	ProcedureCode string            `json:"procedureCode"`
+	ProcedureDate string            `json:"procedureDate"`
+	BillCode      string            `json:"billCode"`
+	SrvcUnitNbr   string            `json:"SrvcUnitNbr"`
+	RevenueCode   string            `json:"revenueCode"`
+	RevenueDesc   string            `json:"revenueDesc"`
+	AdmsnHourCode string            `json:"admsnHourCode"`
+	AdmsnTypeCode string            `json:"admsnTypeCode"`
+	AdmsnSrvcCode string            `json:"admsnSrvcCode"`
+	UnitOfService string            `json:"unitOfService"`
+	ChargedAmount string            `json:"chargedAmount"`
+	NonCovAmount  string            `json:"nonCovAmount"`
+	Owner         string            `json:"owner"`
+	ApprovedAmt   string            `json:"approvedAmt"`
+	UnpaidAmt     string            `json:"unpaidAmt"`
+	CnsnsNote     string            `json:"cnsnsNote"`
+	CnsnsStatus   string            `json:"cnsnsStatus"`
+	Rounds        []Proposal        `json:"rounds,omitempty"`
+	AcceptedRound int               `json:"acceptedRound,omitempty"`
+	CnsnsDeadline string            `json:"cnsnsDeadline,omitempty"`
+	EscalatedBy   string            `json:"escalatedBy,omitempty"`
+	Escalated     string            `json:"escalated,omitempty"`
+	CnsnsSigner   string            `json:"cnsnsSigner,omitempty"`
+	CnsnsSigned   string            `json:"cnsnsSigned,omitempty"`
+	State         string            `json:"state,omitempty"`
+	Created       string            `json:"created,omitempty"`
+	Documents     []Document        `json:"documents,omitempty"`
+	PrivateSalt   string            `json:"privateSalt,omitempty"`
+	PrivateHashes map[string]string `json:"privateHashes,omitempty"`
 }
 
 //==============================================================================================================================
-//	 Init - Initialize the process by creating one record in system validating owner and then storing the information
+//	Claim_Holder - Holds the ClaimIDs of every claim created. Used as an index when querying all claims.
+//==============================================================================================================================
+type Claim_Holder struct {
+	ClaimIDs []string `json:"claimIds"`
+}
+
+//==============================================================================================================================
+//	 Init - Called when the chaincode is deployed. Sealing a claim's PHI needs the collection keys, which can only be
+//			registered once the chaincode is deployed, so deploying creates no claim. Claims are created with the
+//			create_consensus invoke.
 //==============================================================================================================================
 func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	var A, B, C, D, E, F, G string // Entities
-	///var names = []string{"user_type1_0"} //username
-
-	var err error
-	var callerName string
-
-	if len(args) != 20 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 20")
-	}
-
-	//get caller name
-	//callerName, err = t.get_username(stub)
-	//if callerName != "user_type1_0" { // Only the Provider can create a new claim
-
-	//	return nil, fmt.Errorf("Permission Denied. User is not authorized to create record%s==%s", callerName, Initiator)
-
-	//}
-	//if err != nil {
-	//	return nil, fmt.Errorf("Not got the user details from back end")
-	//}
-	// Initialize the chaincode
-	A = args[0]
-	B = args[1]
-	C = args[2]
-	D = args[3]
-	E = args[4]
-	F = args[5]
-	G = args[6]
-	H := args[7]
-	I := args[8]
-	J := args[9]
-	K := args[10]
-	L := args[11]
-	M := args[12]
-	N := args[13]
-	O := args[14]
-	P := args[15]
-	Q := args[16]
-	R := args[17]
-	S := args[18]
-	T := args[19]
-	_, err = t.create_consensus(stub, callerName, A, B, C, D, E, F, G, H, I, J, K, L, M, N, O, P, Q, R, S, T)
-	if err != nil {
-		return nil, fmt.Errorf("Not able to create claim")
-	}
+
 	return nil, nil
 }
 
@@ -149,44 +129,83 @@ func (t *SimpleChaincode) get_username(stub shim.ChaincodeStubInterface) (string
 	return string(username), nil
 }
 
+//==============================================================================================================================
+//	 ROLE_ATTRIBUTE - The ecert attribute granting a caller's role. Callers name their role in the arguments but are only
+//					  accepted from a transaction signed with an ecert granting it.
+//==============================================================================================================================
+const ROLE_ATTRIBUTE = "role"
+
+//==============================================================================================================================
+//	 verify_role - Returns an error unless the ecert of the user who invoked the chaincode grants the role passed in.
+//==============================================================================================================================
+func (t *SimpleChaincode) verify_role(stub shim.ChaincodeStubInterface, role string) error {
+
+	ok, err := stub.VerifyAttribute(ROLE_ATTRIBUTE, []byte(role))
+	if err != nil {
+		return errors.New("Couldn't verify attribute '" + ROLE_ATTRIBUTE + "'. Error: " + err.Error())
+	}
+	if !ok {
+		return errors.New("Permission Denied. The caller's certificate does not grant the " + role + " role")
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 verified_caller - Returns the caller named in the arguments once it has been checked against the ecert. Every
+//					   check on the parties, from the fields they own to the votes they cast, relies on it.
+//==============================================================================================================================
+func (t *SimpleChaincode) verified_caller(stub shim.ChaincodeStubInterface, caller string) (string, error) {
+
+	switch caller {
+	case Initiator, Host, Home, CFA, Admin, Arbiter:
+	default:
+		return "", errors.New("Permission Denied. Unknown caller " + caller)
+	}
+	err := t.verify_role(stub, caller)
+	if err != nil {
+		return "", err
+	}
+	return caller, nil
+}
+
 //=================================================================================================================================
 //	 Create Function
 //=================================================================================================================================
 //	 Create Vehicle - Creates the initial JSON for the vehcile and then saves it to the ledger.
 //=================================================================================================================================
 func (t *SimpleChaincode) create_consensus(stub shim.ChaincodeStubInterface, caller string, arg0 string, arg1 string, arg2 string, arg3 string, arg4 string, arg5 string, arg6 string, arg7 string, arg8 string, arg9 string, arg10 string, arg11 string, arg12 string, arg13 string, arg14 string, arg15 string, arg16 string, arg17 string, arg18 string, arg19 string) ([]byte, error) {
-	var c Claim
-	var err error
-	claimID := "\"claimId\":\"" + arg0 + "\", "
-	ServiceDate := "\"serviceDate\":\"" + arg1 + "\", "
-	AdmissionDate := "\"admissionDate\":\"" + arg2 + "\", "
-	ProviderID := "\"providerId\":\"" + arg3 + "\", "
-	MemberID := "\"memberId\":\"" + arg4 + "\", "
-	SubscriberID := "\"subscriberId\":\"" + arg5 + "\", "
-	DiagCode := "\"diagCode\":\"" + arg6 + "\", "
-	ProcedureCode := "\"procedureCode\":\"" + arg7 + "\", "
-	ProcedureDate := "\"procedureDate\":\"" + arg8 + "\", "
-	BillCode := "\"billCode\":\"" + arg9 + "\", "
-	SrvcUnitNbr := "\"SrvcUnitNbr\":\"" + arg10 + "\", "
-	RevenueCode := "\"revenueCode\":\"" + arg11 + "\", "
-	RevenueDesc := "\"revenueDesc\":\"" + arg12 + "\", "
-	AdmsnHourCode := "\"admsnHourCode\":\"" + arg13 + "\", "
-	AdmsnTypeCode := "\"admsnTypeCode\":\"" + arg14 + "\", "
-	AdmsnSrvcCode := "\"admsnSrvcCode\":\"" + arg15 + "\", "
-	UnitOfService := "\"unitOfService\":\"" + arg16 + "\", "
-	ChargedAmount := "\"chargedAmount\":\"" + arg17 + "\", "
-	NonCovAmount := "\"nonCovAmount\":\"" + arg18 + "\", "
-	Owner := "\"owner\":\"" + arg19 + "\" ,"
-	ApprovedAmt := "\"approvedAmt\":\"UNDEFINED\", "
-	UnpaidAmt := "\"unpaidAmt\":\"UNDEFINED\", "
-	CnsnsNote := "\"cnsnsNote\":\"UNDEFINED\", "
-	CnsnsStatus := "\"cnsnsStatus\":\"CONSENSUSINITIATED\" "
-	consensus_json := "{" + claimID + ServiceDate + AdmissionDate + ProviderID + MemberID + SubscriberID + DiagCode + ProcedureCode + ProcedureDate + BillCode + SrvcUnitNbr + RevenueCode + RevenueDesc + AdmsnHourCode + AdmsnTypeCode + AdmsnSrvcCode + UnitOfService + ChargedAmount + NonCovAmount + Owner + ApprovedAmt + UnpaidAmt + CnsnsNote + CnsnsStatus + "}" // Concatenates the variables to create the total JSON object
-
-	err = json.Unmarshal([]byte(consensus_json), &c) // Convert the JSON defined above into a Claim object for go
-
-	if err != nil {
-		return nil, errors.New("Invalid JSON object")
+	if caller != Initiator {
+		return nil, errors.New("Permission Denied. Only an Initiator can create claims")
+	}
+	if arg0 == "" {
+		return nil, errors.New("A claim id is required")
+	}
+
+	c := Claim{ // Each argument only ever sets its own field
+		ClaimID:       arg0,
+		ServiceDate:   arg1,
+		AdmissionDate: arg2,
+		ProviderID:    arg3,
+		MemberID:      arg4,
+		SubscriberID:  arg5,
+		DiagCode:      arg6,
+		ProcedureCode: arg7,
+		ProcedureDate: arg8,
+		BillCode:      arg9,
+		SrvcUnitNbr:   arg10,
+		RevenueCode:   arg11,
+		RevenueDesc:   arg12,
+		AdmsnHourCode: arg13,
+		AdmsnTypeCode: arg14,
+		AdmsnSrvcCode: arg15,
+		UnitOfService: arg16,
+		ChargedAmount: arg17,
+		NonCovAmount:  arg18,
+		Owner:         arg19,
+		ApprovedAmt:   AMOUNT_UNDEFINED,
+		UnpaidAmt:     AMOUNT_UNDEFINED,
+		CnsnsNote:     "UNDEFINED",
+		CnsnsStatus:   CNSNS_INITIATED,
 	}
 
 	record, err := stub.GetState(c.ClaimID)
@@ -196,25 +215,34 @@ func (t *SimpleChaincode) create_consensus(stub shim.ChaincodeStubInterface, cal
 		return nil, errors.New("Claim already exists")
 	}
 
+	c.State = STATE_INITIATE
+	c.Created, err = tx_timestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	hours, err := t.retrieve_window(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = start_window(stub, &c, hours) // The parties have until the deadline to reach consensus
+	if err != nil {
+		return nil, err
+	}
+
 	_, err = t.save_changes(stub, c)
 
 	if err != nil {
 		fmt.Printf("CREATE_CLAIM: Error saving changes: %s", err)
 		return nil, errors.New("Error saving changes")
 	}
-	bytes, err := stub.GetState(c.ClaimID)
-	if err != nil {
-		return nil, errors.New("Error in retriving information")
-	}
-	err = stub.PutState("State", []byte("CONSENSUSINITIATED"))
+	err = t.index_claim(stub, c.ClaimID)
 	if err != nil {
-		return nil, errors.New("Error in putting information")
+		return nil, err
 	}
-
-	stringVal := "[" + arg0 + "]"
-	err = stub.PutState("ClaimID", []byte(stringVal))
+	bytes, err := stub.GetState(c.ClaimID)
 	if err != nil {
-		return nil, errors.New("Error in putting claim information in ledger")
+		return nil, errors.New("Error in retriving information")
 	}
 	return bytes, nil
 
@@ -226,6 +254,19 @@ func (t *SimpleChaincode) create_consensus(stub shim.ChaincodeStubInterface, cal
 //==============================================================================================================================
 func (t *SimpleChaincode) save_changes(stub shim.ChaincodeStubInterface, c Claim) (bool, error) {
 
+	err := check_cnsns_status(c.CnsnsStatus)
+	if err != nil {
+		return false, err
+	}
+	err = t.index_cnsns_status(stub, c) // Keep the status index in step with the claim
+	if err != nil {
+		return false, err
+	}
+	err = t.seal_private(stub, &c) // PHI is only written to its private collections
+	if err != nil {
+		return false, err
+	}
+
 	bytes, err := json.Marshal(c)
 
 	if err != nil {
@@ -244,127 +285,366 @@ func (t *SimpleChaincode) save_changes(stub shim.ChaincodeStubInterface, c Claim
 }
 
 //==============================================================================================================================
-//	 Router Functions
-//==============================================================================================================================
-//	Invoke - Called on chaincode invoke. Takes a function name passed and calls that function. Converts some
-//		  initial arguments passed to other things for use in the called function e.g. name -> ecert
+//	 retrieve_claim - Gets a Claim from the ledger.
 //==============================================================================================================================
-func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+func (t *SimpleChaincode) retrieve_claim(stub shim.ChaincodeStubInterface, claimId string) (Claim, error) {
 
-	var claimId string //get input from front end
-	var err error
-	var c Claim // claim object
-	claimId = args[1]
+	var c Claim
 
 	bytes, err := stub.GetState(claimId)
-
 	if err != nil {
-		return nil, errors.New("The claim id is not available in back end")
+		return c, errors.New("Error retrieving claim " + claimId)
+	}
+	if bytes == nil {
+		return c, errors.New("Claim " + claimId + " does not exist")
 	}
 	err = json.Unmarshal(bytes, &c)
 	if err != nil {
-		return nil, errors.New("Unmarshalling failed for claim")
+		return c, errors.New("Unmarshalling failed for claim " + claimId)
 	}
-	storedUser := c.Owner
-	if function == "transfer_to_home" {
-		return t.transfer_to_home(stub, claimId, c, args[0], storedUser)
-	} else if function == "update_by_home" {
-		return t.update_by_home(stub, claimId, c, args[0], args[2], args[3], args[4], storedUser)
-	} else if function == "transfer_to_hostByHome" {
-		return t.transfer_to_hostByHome(stub, claimId, c, args[0], storedUser)
-	}
-	return nil, nil
+	return c, nil
+}
+
+//==============================================================================================================================
+//	 retrieve_claim_ids - Gets the index of ClaimIDs from the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_claim_ids(stub shim.ChaincodeStubInterface) (Claim_Holder, error) {
+
+	var claimIDs Claim_Holder
 
+	bytes, err := stub.GetState("ClaimIDs")
+	if err != nil {
+		return claimIDs, errors.New("Unable to get ClaimIDs")
+	}
+	if bytes == nil {
+		return claimIDs, nil
+	}
+	err = json.Unmarshal(bytes, &claimIDs)
+	if err != nil {
+		return claimIDs, errors.New("Corrupt Claim_Holder record")
+	}
+	return claimIDs, nil
 }
 
-//=================================================================================================================================
-//	Query - Called on chaincode query. Takes a function name passed and calls that function. Passes the
-//  		initial arguments passed are passed on to the called function.
-//=================================================================================================================================
-func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	var c Claim
-	//var byteReturn []byte
+//==============================================================================================================================
+//	 index_claim - Adds a new ClaimID to the index of all claims and writes its key for range scans of the claims.
+//==============================================================================================================================
+func (t *SimpleChaincode) index_claim(stub shim.ChaincodeStubInterface, claimId string) error {
 
-	stateBytes, errs := stub.GetState("State")
-	if errs != nil {
-		return nil, fmt.Errorf("Error with State")
+	err := stub.PutState(claim_index_key(claimId), []byte(claimId))
+	if err != nil {
+		return errors.New("Unable to put the state")
 	}
-	if function == "get_claim_id" {
-		claimInfo, errors := stub.GetState("ClaimID")
-		if errors != nil {
-			return nil, fmt.Errorf("Error with Claim")
-		}
-		return claimInfo, nil
-	} else if function == "get_claim_need_consensus" {
 
-		//if err == nil {
-		//	return bytes, nil
-		//}
-		fmt.Printf("Starting function get_claim_details")
+	claimIDs, err := t.retrieve_claim_ids(stub)
+	if err != nil {
+		return err
+	}
+	claimIDs.ClaimIDs = append(claimIDs.ClaimIDs, claimId)
 
-		if len(args) != 2 {
-			return nil, errors.New("Argument number is not correct")
-		}
-		caller := args[0]
-		claimID := args[1]
-		bytes, err := stub.GetState(claimID)
-		if err != nil {
-			return nil, errors.New("not received state details")
-		}
+	bytes, err := json.Marshal(claimIDs)
+	if err != nil {
+		return errors.New("Error creating Claim_Holder record")
+	}
+	err = stub.PutState("ClaimIDs", bytes)
+	if err != nil {
+		return errors.New("Unable to put the state")
+	}
+	return nil
+}
 
-		err = json.Unmarshal(bytes, &c)
+//==============================================================================================================================
+//	 Router Functions
+//==============================================================================================================================
+//	Invoke - Called on chaincode invoke. Takes a function name passed and calls that function. Converts some
+//		  initial arguments passed to other things for use in the called function e.g. name -> ecert
+//==============================================================================================================================
+func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 
+	return router.Dispatch(stub, "Invoke", t.invoke_routes(), function, args)
+
+}
+
+//==============================================================================================================================
+//	claim_handler - An Invoke function acting on one claim. It is passed the stored claim and the full argument list.
+//==============================================================================================================================
+type claim_handler func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error)
+
+//==============================================================================================================================
+//	 invoke_routes - The functions Invoke can call. Configuration functions do not act on a claim, the others take the
+//					 caller and ClaimID as their first two arguments.
+//==============================================================================================================================
+func (t *SimpleChaincode) invoke_routes() map[string]router.Route {
+
+	create := router.Exactly(20, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+		caller, err := t.verified_caller(stub, args[19]) // The owner in args[19] creates the claim
 		if err != nil {
-			return nil, fmt.Errorf("Nort able to unmarshall the status")
-		}
-		byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
-		if err != nil {
-			return nil, fmt.Errorf("Error with getClaimDetails")
+			return nil, err
 		}
-		err = json.Unmarshal(byteReturn, &c)
-		outPut := c.ClaimID
-		fmt.Printf("The value is: %s", outPut)
-		return byteReturn, nil
+		return t.create_consensus(stub, caller, args[0], args[1], args[2], args[3], args[4], args[5], args[6], args[7], args[8], args[9], args[10], args[11], args[12], args[13], args[14], args[15], args[16], args[17], args[18], args[19])
+	})
+
+	return map[string]router.Route{
+		"create_consensus": create,
+		"Init":             create, // Clients written before create_consensus created claims by invoking Init
+		"set_quorum_policy": router.Exactly(4, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.set_quorum_policy(stub, caller, args[1], args[2], args[3])
+		}),
+		"register_party_key": router.Exactly(3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.register_party_key(stub, caller, args[1], args[2])
+		}),
+		"register_collection_key": router.Exactly(3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.register_collection_key(stub, caller, args[1], args[2])
+		}),
+		"index_legacy_claims": router.Exactly(1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.index_legacy_claims(stub, caller)
+		}),
+		"set_consensus_window": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.set_consensus_window(stub, caller, args[1])
+		}),
+
+		"transfer_to_home": t.claim_route(2, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.transfer_to_home(stub, claimId, c, args[0], storedUser)
+		}),
+		"transfer_to_hostByHome": t.claim_route(2, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.transfer_to_hostByHome(stub, claimId, c, args[0], storedUser)
+		}),
+		"update_by_home": t.claim_route(5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.update_by_home(stub, claimId, c, args[0], args[2], args[3], args[4], storedUser)
+		}),
+		"update_by_host": t.claim_route(5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.update_by_host(stub, claimId, c, args[0], args[2], args[3], args[4], storedUser)
+		}),
+		"propose_consensus": t.claim_route(5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.propose_consensus(stub, claimId, c, args[0], args[2], args[3], args[4])
+		}),
+		"vote_consensus": t.claim_route_between(4, 5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			signature := ""
+			if len(args) == 5 {
+				signature = args[4]
+			}
+			return t.vote_consensus(stub, claimId, c, args[0], args[2], args[3], signature)
+		}),
+		"counter_propose": t.claim_route(5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.counter_propose(stub, claimId, c, args[0], args[2], args[3], args[4])
+		}),
+		"set_claim_window": t.claim_route(3, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.set_claim_window(stub, claimId, c, args[0], args[2])
+		}),
+		"escalate": t.claim_route(2, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.escalate(stub, claimId, c, args[0])
+		}),
+		"arbitrate": t.claim_route(5, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.arbitrate(stub, claimId, c, args[0], args[2], args[3], args[4])
+		}),
+		"attach_document": t.claim_route(6, func(stub shim.ChaincodeStubInterface, claimId string, c Claim, storedUser string, args []string) ([]byte, error) {
+			return t.attach_document(stub, claimId, c, args[0], args[2], args[3], args[4], args[5])
+		}),
+	}
+}
 
-	} else if function == "consensus_agreed" {
-		fmt.Printf("Starting function allow_to_update")
+//==============================================================================================================================
+//	 claim_route - Returns a Route for an Invoke function taking n arguments and acting on the claim in args[1], which is
+//				   loaded before the handler runs.
+//==============================================================================================================================
+func (t *SimpleChaincode) claim_route(n int, handler claim_handler) router.Route {
+	return t.claim_route_between(n, n, handler)
+}
 
-		if len(args) != 2 {
-			return nil, errors.New("Argument number is not correct")
-		}
-		caller := args[0]
-		claimID := args[1]
-		bytes, err := stub.GetState(claimID)
+//==============================================================================================================================
+//	 claim_route_between - Returns a Route for an Invoke function taking from minArgs to maxArgs arguments and acting on
+//						   the claim in args[1]. The caller in args[0] is verified before the claim is loaded.
+//==============================================================================================================================
+func (t *SimpleChaincode) claim_route_between(minArgs int, maxArgs int, handler claim_handler) router.Route {
+
+	return router.Between(minArgs, maxArgs, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+		var c Claim // claim object
+		claimId := args[1]
+
+		_, err := t.verified_caller(stub, args[0])
 		if err != nil {
-			return nil, errors.New("not received state details")
+			return nil, err
 		}
+		bytes, err := stub.GetState(claimId)
 
+		if err != nil || bytes == nil {
+			return nil, errors.New("The claim id " + claimId + " is not available in back end")
+		}
 		err = json.Unmarshal(bytes, &c)
-
 		if err != nil {
-			return nil, fmt.Errorf("Nort able to unmarshall the status")
+			return nil, errors.New("Unmarshalling failed for claim")
 		}
-		currentState := string(stateBytes)
-		if caller == Host && currentState == STATE_INITIATE {
-			byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+		storedUser := c.Owner
+		return handler(stub, claimId, c, storedUser, args)
+	})
+}
+
+//=================================================================================================================================
+//	Query - Called on chaincode query. Takes a function name passed and calls that function. Passes the
+//  		initial arguments passed are passed on to the called function.
+//=================================================================================================================================
+func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+
+	return router.Dispatch(stub, "Query", t.query_routes(), function, args)
+
+}
+
+//=================================================================================================================================
+//	 query_routes - The functions Query can call.
+//=================================================================================================================================
+func (t *SimpleChaincode) query_routes() map[string]router.Route {
+
+	return map[string]router.Route{
+		"get_claim_id": router.Between(0, 1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			return t.get_claim_ids(stub)
+		}),
+		"claims_by_cnsns_status": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, err := t.verified_caller(stub, args[0])
 			if err != nil {
-				return nil, fmt.Errorf("Error with getClaimDetails")
+				return nil, err
 			}
-			return byteReturn, nil
-			//return stateBytes, nil
-		} else if caller == Host && currentState == STATE_HOME_HOST {
-			byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+			return t.claims_by_cnsns_status(stub, caller, args[1])
+		}),
+		"claims_awaiting_my_vote": router.Exactly(1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, err := t.verified_caller(stub, args[0])
 			if err != nil {
-				return nil, fmt.Errorf("Error with getClaimDetails")
+				return nil, err
 			}
-			return byteReturn, nil
-		} else if caller == Home && currentState == STATE_HOME {
-			byteReturn, err := t.get_claim_details(stub, claimID, c, caller)
+			return t.claims_awaiting_my_vote(stub, caller)
+		}),
+		"get_quorum_policy": router.Between(0, 1, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			return t.get_quorum_policy(stub)
+		}),
+		"expired_consensus": router.Between(1, 2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			asOf := ""
+			if len(args) == 2 {
+				asOf = args[1]
+			}
+			caller, err := t.verified_caller(stub, args[0])
 			if err != nil {
-				return nil, fmt.Errorf("Error with getClaimDetails")
+				return nil, err
 			}
-			return byteReturn, nil
-		}
+			return t.expired_consensus(stub, caller, asOf)
+		}),
+		"get_votes": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			_, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			c, err := t.retrieve_claim(stub, args[1])
+			if err != nil {
+				return nil, err
+			}
+			return t.get_votes(stub, c)
+		}),
+		"get_signatures": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			_, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			c, err := t.retrieve_claim(stub, args[1])
+			if err != nil {
+				return nil, err
+			}
+			return t.get_signatures(stub, c)
+		}),
+		"get_rounds": router.Exactly(2, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			_, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			c, err := t.retrieve_claim(stub, args[1])
+			if err != nil {
+				return nil, err
+			}
+			return t.get_rounds(stub, c)
+		}),
+		"verify_document": router.Exactly(4, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			caller, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			c, err := t.retrieve_claim(stub, args[1])
+			if err != nil {
+				return nil, err
+			}
+			return t.verify_document(stub, c, caller, args[2], args[3])
+		}),
+		"consensus_summary": router.Between(1, 3, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			startKey, pageSize := "", ""
+			if len(args) > 1 {
+				startKey = args[1]
+			}
+			if len(args) > 2 {
+				pageSize = args[2]
+			}
+			caller, err := t.verified_caller(stub, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return t.consensus_summary(stub, caller, startKey, pageSize)
+		}),
+		"get_claim_need_consensus": router.Exactly(2, t.query_claim_need_consensus),
+		"consensus_agreed":         router.Exactly(2, t.query_consensus_agreed),
+	}
+}
+
+//=================================================================================================================================
+//	 query_claim_need_consensus - Returns the claim in args[1].
+//=================================================================================================================================
+func (t *SimpleChaincode) query_claim_need_consensus(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	caller, err := t.verified_caller(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	c, err := t.retrieve_claim(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+	return t.get_claim_details(stub, c.ClaimID, c, caller)
+}
+
+//=================================================================================================================================
+//	 query_consensus_agreed - Returns the claim in args[1] if the caller in args[0] is the party it is waiting on.
+//=================================================================================================================================
+func (t *SimpleChaincode) query_consensus_agreed(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+
+	caller, err := t.verified_caller(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	c, err := t.retrieve_claim(stub, args[1])
+	if err != nil {
+		return nil, err
+	}
+	currentState, err := t.claim_state(stub, c)
+	if err != nil {
+		return nil, err
+	}
+	if (caller == Host && (currentState == STATE_INITIATE || currentState == STATE_HOME_HOST)) || (caller == Home && currentState == STATE_HOME) {
+		return t.get_claim_details(stub, c.ClaimID, c, caller)
 	}
 	return nil, nil //all done
 }
@@ -380,16 +660,13 @@ func (t *SimpleChaincode) transfer_to_home(stub shim.ChaincodeStubInterface, cla
 		return nil, errors.New("The intended user is not Home")
 	}
 	c.Owner = caller
+	c.State = STATE_HOME
 
 	_, err := t.save_changes(stub, c) // Write new state
 
 	if err != nil {
 		return nil, errors.New("Not able to save state")
 	}
-	errs := stub.PutState("State", []byte(STATE_HOME))
-	if errs != nil {
-		return nil, errors.New("Not able to save state")
-	}
 	return nil, nil // We are Done
 
 }
@@ -405,16 +682,13 @@ func (t *SimpleChaincode) transfer_to_hostByHome(stub shim.ChaincodeStubInterfac
 		return nil, errors.New("The intended user is not Home")
 	}
 	c.Owner = caller
+	c.State = STATE_HOME_HOST
 
 	_, err := t.save_changes(stub, c) // Write new state
 
 	if err != nil {
 		return nil, errors.New("Not able to save state")
 	}
-	errs := stub.PutState("State", []byte(STATE_HOME_HOST))
-	if errs != nil {
-		return nil, errors.New("Not able to save state")
-	}
 	return nil, nil // We are Done
 
 }
@@ -422,39 +696,47 @@ func (t *SimpleChaincode) transfer_to_hostByHome(stub shim.ChaincodeStubInterfac
 //=================================================================================================================================
 //	 Update Functions
 //=================================================================================================================================
-//	 update_by_home
+//	 update_by_host - The Host sets the approved amount and note of the next round. The unpaid amount is left as it is
+//					  and must be passed empty or unchanged.
+//=================================================================================================================================
+func (t *SimpleChaincode) update_by_host(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, approvedAmt string, unpaidAmt string, cnsnsNote string, storedUser string) ([]byte, error) {
+
+	if caller != Host {
+		return nil, errors.New("Permission Denied. update_by_host is for the Host")
+	}
+	return t.update_consensus(stub, claimId, c, caller, approvedAmt, unpaidAmt, cnsnsNote)
+
+}
+
+//=================================================================================================================================
+//	 update_by_home - The Home sets the unpaid amount and note of the next round. The approved amount is left as it is
+//					  and must be passed empty or unchanged.
 //=================================================================================================================================
 func (t *SimpleChaincode) update_by_home(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, approvedAmt string, unpaidAmt string, cnsnsNote string, storedUser string) ([]byte, error) {
 
 	user := caller
 	fmt.Printf("The Owner is: %s", user)
-	if user != Host {
-		return nil, errors.New("Owner is not matching")
+	if user != Home {
+		return nil, errors.New("Permission Denied. update_by_home is for the Home")
 	}
-	c.ApprovedAmt = approvedAmt
-	c.UnpaidAmt = unpaidAmt
-	c.CnsnsNote = cnsnsNote
-	c.CnsnsStatus = "CONSENSUSRCHD"
-	_, err := t.save_changes(stub, c) // Write new state
-
-	if err != nil {
-		return nil, errors.New("Not able to save state")
-	}
-
-	return nil, nil // We are Done
+	return t.update_consensus(stub, claimId, c, caller, approvedAmt, unpaidAmt, cnsnsNote)
 
 }
 
 //=================================================================================================================================
 //	 Get Functions
 //=================================================================================================================================
-//	 get_claim_details
+//	 get_claim_details - Returns a claim with the private fields the caller may read.
 //=================================================================================================================================
 func (t *SimpleChaincode) get_claim_details(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string) ([]byte, error) {
 
 	user := caller
 	fmt.Printf("The Owner is: %s", user)
 
+	err := t.reveal_private(stub, &c, caller)
+	if err != nil {
+		return nil, err
+	}
 	bytes, err := json.Marshal(c)
 
 	if err != nil {
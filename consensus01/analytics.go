@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	 Range scan keys - Every claim has a "ClaimIndex_" + ClaimID key so claims can be read in pages with RangeQueryState.
+//					   CLAIM_INDEX_END sorts after every key with the prefix.
+//==============================================================================================================================
+const CLAIM_INDEX_PREFIX = "ClaimIndex_"
+const CLAIM_INDEX_END = "ClaimIndex`"
+
+const DEFAULT_PAGE_SIZE = 100
+const MAX_PAGE_SIZE = 500
+
+//==============================================================================================================================
+//	 deltaBuckets - Bands of ChargedAmount minus ApprovedAmt, in cents, that agreed claims are counted in. A claim falls
+//					in the first band whose Max it does not exceed.
+//==============================================================================================================================
+var deltaBuckets = []Delta_Bucket{
+	{Label: "<0", Max: -1},
+	{Label: "0", Max: 0},
+	{Label: "0.01-50.00", Max: 5000},
+	{Label: "50.01-100.00", Max: 10000},
+	{Label: "100.01-500.00", Max: 50000},
+	{Label: "500.01-1000.00", Max: 100000},
+	{Label: ">1000.00", Max: math.MaxInt64},
+}
+
+//==============================================================================================================================
+//	Delta_Bucket - The number of agreed claims whose charged less approved amount falls in a band.
+//==============================================================================================================================
+type Delta_Bucket struct {
+	Label string        `json:"label"`
+	Max   amount.Amount `json:"-"`
+	Count int           `json:"count"`
+}
+
+//==============================================================================================================================
+//	Consensus_Summary - How often and how quickly Host and Home agree and how far the agreed amount is from the charge,
+//						over one page of claims. Totals are given alongside the averages so a client can combine pages.
+//						NextKey is the ClaimID to start the next page from and is empty on the last page.
+//==============================================================================================================================
+type Consensus_Summary struct {
+	ClaimsScanned           int            `json:"claimsScanned"`
+	StatusCounts            map[string]int `json:"statusCounts"`
+	AgreedClaims            int            `json:"agreedClaims"`
+	TotalHoursToConsensus   float64        `json:"totalHoursToConsensus"`
+	AverageHoursToConsensus float64        `json:"averageHoursToConsensus"`
+	DeltaCount              int            `json:"deltaCount"`
+	DeltaTotal              string         `json:"deltaTotal"`
+	DeltaAverage            string         `json:"deltaAverage"`
+	DeltaMin                string         `json:"deltaMin"`
+	DeltaMax                string         `json:"deltaMax"`
+	DeltaBuckets            []Delta_Bucket `json:"deltaBuckets"`
+	NextKey                 string         `json:"nextKey"`
+}
+
+//==============================================================================================================================
+//	 claim_index_key - Ledger key marking a claim for range scans.
+//==============================================================================================================================
+func claim_index_key(claimId string) string {
+	return CLAIM_INDEX_PREFIX + claimId
+}
+
+//==============================================================================================================================
+//	 consensus_started - Returns when the parties started working towards consensus on a claim: its creation, or the
+//						 first proposal on claims created before creation times were kept.
+//==============================================================================================================================
+func consensus_started(c Claim) string {
+
+	if c.Created != "" {
+		return c.Created
+	}
+	if len(c.Rounds) > 0 {
+		return c.Rounds[0].Proposed
+	}
+	return ""
+}
+
+//=================================================================================================================================
+//	 Analytics Functions
+//=================================================================================================================================
+//	 consensus_summary - Summarises up to pageSize claims in ClaimID order from startKey, scanning the claim index keys
+//						 rather than loading every claim at once. Time to consensus runs from creation until the vote
+//						 or arbitration that reached it.
+//=================================================================================================================================
+func (t *SimpleChaincode) consensus_summary(stub shim.ChaincodeStubInterface, caller string, startKey string, pageSize string) ([]byte, error) {
+
+	if caller != Admin && caller != Arbiter && caller != Host && caller != Home && caller != CFA {
+		return nil, errors.New("Permission Denied. " + caller + " can not view consensus analytics")
+	}
+	size := DEFAULT_PAGE_SIZE
+	if pageSize != "" {
+		n, err := strconv.Atoi(pageSize)
+		if err != nil || n < 1 || n > MAX_PAGE_SIZE {
+			return nil, errors.New("Invalid page size " + pageSize + ", expecting 1 to " + strconv.Itoa(MAX_PAGE_SIZE))
+		}
+		size = n
+	}
+
+	iter, err := stub.RangeQueryState(claim_index_key(startKey), CLAIM_INDEX_END)
+	if err != nil {
+		return nil, errors.New("Unable to scan claims. Error: " + err.Error())
+	}
+	defer iter.Close()
+
+	summary := Consensus_Summary{StatusCounts: make(map[string]int)}
+	buckets := make([]Delta_Bucket, len(deltaBuckets))
+	copy(buckets, deltaBuckets)
+	var deltaTotal, deltaMin, deltaMax amount.Amount
+
+	for iter.HasNext() {
+		_, value, err := iter.Next()
+		if err != nil {
+			return nil, errors.New("Unable to scan claims. Error: " + err.Error())
+		}
+		claimId := string(value)
+		if summary.ClaimsScanned == size {
+			summary.NextKey = claimId
+			break
+		}
+		c, err := t.retrieve_claim(stub, claimId)
+		if err != nil {
+			return nil, err
+		}
+		summary.ClaimsScanned++
+		summary.StatusCounts[c.CnsnsStatus]++
+		if c.CnsnsStatus != CNSNS_REACHED {
+			continue
+		}
+
+		started, err := time.Parse(time.RFC3339, consensus_started(c))
+		if err == nil && c.CnsnsSigned != "" {
+			signed, err := time.Parse(time.RFC3339, c.CnsnsSigned)
+			if err != nil {
+				return nil, errors.New("Corrupt consensus timestamp on claim " + claimId)
+			}
+			summary.AgreedClaims++
+			summary.TotalHoursToConsensus += signed.Sub(started).Hours()
+		}
+
+		if c.ApprovedAmt == AMOUNT_UNDEFINED || strings.TrimSpace(c.ChargedAmount) == "" || c.ChargedAmount == AMOUNT_UNDEFINED {
+			continue
+		}
+		charged, err := amount.ParseRounded(c.ChargedAmount)
+		if err != nil {
+			continue // Charges are not validated when a claim is created
+		}
+		approved, err := amount.ParseRounded(c.ApprovedAmt)
+		if err != nil {
+			return nil, errors.New("Corrupt approved amount on claim " + claimId)
+		}
+		delta := charged - approved
+		if summary.DeltaCount == 0 || delta < deltaMin {
+			deltaMin = delta
+		}
+		if summary.DeltaCount == 0 || delta > deltaMax {
+			deltaMax = delta
+		}
+		summary.DeltaCount++
+		deltaTotal += delta
+		for i := range buckets {
+			if delta <= buckets[i].Max {
+				buckets[i].Count++
+				break
+			}
+		}
+	}
+
+	if summary.AgreedClaims > 0 {
+		summary.AverageHoursToConsensus = summary.TotalHoursToConsensus / float64(summary.AgreedClaims)
+	}
+	summary.DeltaTotal = deltaTotal.String()
+	summary.DeltaMin = deltaMin.String()
+	summary.DeltaMax = deltaMax.String()
+	summary.DeltaAverage = amount.Amount(0).String()
+	if summary.DeltaCount > 0 {
+		summary.DeltaAverage = (deltaTotal / amount.Amount(summary.DeltaCount)).String()
+	}
+	summary.DeltaBuckets = buckets
+	return json.Marshal(summary)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 cnsns_status_key - Ledger key holding the Claim_Holder index of the claims in a consensus status.
+//==============================================================================================================================
+func cnsns_status_key(status string) string {
+	return "CnsnsStatus_" + status
+}
+
+//==============================================================================================================================
+//	 contains_id - Returns whether a list of ClaimIDs holds the ClaimID passed.
+//==============================================================================================================================
+func contains_id(ids []string, id string) bool {
+
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+//==============================================================================================================================
+//	 retrieve_status_index - Gets the ClaimIDs of the claims in a consensus status from the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_status_index(stub shim.ChaincodeStubInterface, status string) (Claim_Holder, error) {
+
+	var claimIDs Claim_Holder
+
+	bytes, err := stub.GetState(cnsns_status_key(status))
+	if err != nil {
+		return claimIDs, errors.New("Unable to get claims in status " + status)
+	}
+	if bytes == nil {
+		return claimIDs, nil
+	}
+	err = json.Unmarshal(bytes, &claimIDs)
+	if err != nil {
+		return claimIDs, errors.New("Corrupt Claim_Holder record for status " + status)
+	}
+	return claimIDs, nil
+}
+
+//==============================================================================================================================
+//	 save_status_index - Writes the index of the claims in a consensus status to the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) save_status_index(stub shim.ChaincodeStubInterface, status string, claimIDs Claim_Holder) error {
+
+	bytes, err := json.Marshal(claimIDs)
+	if err != nil {
+		return errors.New("Error creating Claim_Holder record")
+	}
+	err = stub.PutState(cnsns_status_key(status), bytes)
+	if err != nil {
+		return errors.New("Unable to put the state")
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 index_cnsns_status - Moves a claim about to be saved from the index of its stored consensus status to the index of
+//						  its new one. Nothing is written when the status has not changed.
+//==============================================================================================================================
+func (t *SimpleChaincode) index_cnsns_status(stub shim.ChaincodeStubInterface, c Claim) error {
+
+	var before Claim
+
+	bytes, err := stub.GetState(c.ClaimID)
+	if err != nil {
+		return errors.New("Unable to get claim " + c.ClaimID)
+	}
+	if bytes != nil {
+		err = json.Unmarshal(bytes, &before)
+		if err != nil {
+			return errors.New("Unmarshalling failed for claim " + c.ClaimID)
+		}
+		if before.CnsnsStatus == c.CnsnsStatus {
+			return nil
+		}
+		old, err := t.retrieve_status_index(stub, before.CnsnsStatus)
+		if err != nil {
+			return err
+		}
+		for i, id := range old.ClaimIDs {
+			if id == c.ClaimID {
+				old.ClaimIDs = append(old.ClaimIDs[:i], old.ClaimIDs[i+1:]...)
+				break
+			}
+		}
+		err = t.save_status_index(stub, before.CnsnsStatus, old)
+		if err != nil {
+			return err
+		}
+	}
+
+	claimIDs, err := t.retrieve_status_index(stub, c.CnsnsStatus)
+	if err != nil {
+		return err
+	}
+	claimIDs.ClaimIDs = append(claimIDs.ClaimIDs, c.ClaimID)
+	return t.save_status_index(stub, c.CnsnsStatus, claimIDs)
+}
+
+//==============================================================================================================================
+//	 claim_state - Returns the STATE_ stage of a claim. Claims created before stages were kept per claim fall back to
+//				   the stage last written to the shared "State" key.
+//==============================================================================================================================
+func (t *SimpleChaincode) claim_state(stub shim.ChaincodeStubInterface, c Claim) (string, error) {
+
+	if c.State != "" {
+		return c.State, nil
+	}
+	bytes, err := stub.GetState("State")
+	if err != nil {
+		return "", errors.New("Error with State")
+	}
+	return string(bytes), nil
+}
+
+//=================================================================================================================================
+//	 Listing Functions
+//=================================================================================================================================
+//	 get_claim_ids - Returns the ClaimIDs of every claim. A ledger from before claims were indexed only holds the last
+//					 claim created under "ClaimID", which is returned instead.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_claim_ids(stub shim.ChaincodeStubInterface) ([]byte, error) {
+
+	claimIDs, err := t.retrieve_claim_ids(stub)
+	if err != nil {
+		return nil, err
+	}
+	if len(claimIDs.ClaimIDs) == 0 {
+		legacy, err := stub.GetState("ClaimID")
+		if err != nil {
+			return nil, errors.New("Error with Claim")
+		}
+		if legacy != nil {
+			return legacy, nil
+		}
+		claimIDs.ClaimIDs = []string{}
+	}
+	return json.Marshal(claimIDs.ClaimIDs)
+}
+
+//=================================================================================================================================
+//	 claims_by_cnsns_status - Returns the claims in a consensus status with the private fields the caller may read.
+//=================================================================================================================================
+func (t *SimpleChaincode) claims_by_cnsns_status(stub shim.ChaincodeStubInterface, caller string, status string) ([]byte, error) {
+
+	if caller != Admin && caller != Arbiter && caller != Host && caller != Home && caller != CFA {
+		return nil, errors.New("Permission Denied. " + caller + " can not list claims")
+	}
+	err := check_cnsns_status(status)
+	if err != nil {
+		return nil, err
+	}
+	claimIDs, err := t.retrieve_status_index(stub, status)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := []Claim{}
+	for _, id := range claimIDs.ClaimIDs {
+		c, err := t.retrieve_claim(stub, id)
+		if err != nil {
+			return nil, err
+		}
+		err = t.reveal_private(stub, &c, caller)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	return json.Marshal(claims)
+}
+
+//=================================================================================================================================
+//	 claims_awaiting_my_vote - Returns the claims whose open round the calling party votes on and has not voted on yet.
+//							   Rounds still waiting for an amount or past the claim's voting window are left out as they
+//							   can not be voted on.
+//=================================================================================================================================
+func (t *SimpleChaincode) claims_awaiting_my_vote(stub shim.ChaincodeStubInterface, caller string) ([]byte, error) {
+
+	now, err := tx_time(stub)
+	if err != nil {
+		return nil, err
+	}
+	claimIDs, err := t.retrieve_status_index(stub, CNSNS_PROPOSED)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := []Claim{}
+	for _, id := range claimIDs.ClaimIDs {
+		c, err := t.retrieve_claim(stub, id)
+		if err != nil {
+			return nil, err
+		}
+		p := current_round(&c)
+		if p == nil || p.Status != ROUND_OPEN || !p.Policy.is_party(caller) {
+			continue
+		}
+		if p.ApprovedAmt == AMOUNT_UNDEFINED || p.UnpaidAmt == AMOUNT_UNDEFINED {
+			continue
+		}
+		closed, err := window_closed(c, now)
+		if err != nil {
+			return nil, err
+		}
+		if closed {
+			continue
+		}
+		voted := false
+		for _, v := range p.Votes {
+			if v.Party == caller {
+				voted = true
+				break
+			}
+		}
+		if !voted {
+			err = t.reveal_private(stub, &c, caller)
+			if err != nil {
+				return nil, err
+			}
+			claims = append(claims, c)
+		}
+	}
+	return json.Marshal(claims)
+}
+
+//=================================================================================================================================
+//	 index_legacy_claims - The Admin adds the claims created before the range scan keys and consensus status indexes
+//						   were kept to them, so listings and the consensus summary include them. These are the claims in
+//						   the ClaimIDs index and the last claim created under "ClaimID". Claims already indexed are left
+//						   as they are, so it can be run again safely.
+//=================================================================================================================================
+func (t *SimpleChaincode) index_legacy_claims(stub shim.ChaincodeStubInterface, caller string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can index claims")
+	}
+	claimIDs, err := t.retrieve_claim_ids(stub)
+	if err != nil {
+		return nil, err
+	}
+	ids := claimIDs.ClaimIDs
+	legacy, err := stub.GetState("ClaimID")
+	if err != nil {
+		return nil, errors.New("Error with Claim")
+	}
+	if legacy != nil && !contains_id(ids, string(legacy)) {
+		ids = append(ids, string(legacy))
+		err = t.index_claim(stub, string(legacy))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, id := range ids {
+		c, err := t.retrieve_claim(stub, id)
+		if err != nil {
+			return nil, err
+		}
+		key, err := stub.GetState(claim_index_key(id))
+		if err != nil {
+			return nil, errors.New("Unable to get the index of claim " + id)
+		}
+		if key == nil {
+			err = stub.PutState(claim_index_key(id), []byte(id))
+			if err != nil {
+				return nil, errors.New("Unable to put the state")
+			}
+		}
+		if check_cnsns_status(c.CnsnsStatus) != nil {
+			continue // A claim in no known status can not be listed by status until it is updated
+		}
+		index, err := t.retrieve_status_index(stub, c.CnsnsStatus)
+		if err != nil {
+			return nil, err
+		}
+		if !contains_id(index.ClaimIDs, id) {
+			index.ClaimIDs = append(index.ClaimIDs, id)
+			err = t.save_status_index(stub, c.CnsnsStatus, index)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
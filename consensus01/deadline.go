@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	 DEFAULT_WINDOW_HOURS - Hours the parties have to reach consensus on a new claim until the Admin sets a window.
+//==============================================================================================================================
+const DEFAULT_WINDOW_HOURS = 72
+
+//==============================================================================================================================
+//	Window_Config - Number of hours from creation that a new claim is open for voting. Stored on the ledger under
+//					"Consensus_Window".
+//==============================================================================================================================
+type Window_Config struct {
+	Hours int `json:"hours"`
+}
+
+//==============================================================================================================================
+//	Expired_Claim - A claim whose voting window closed without consensus.
+//==============================================================================================================================
+type Expired_Claim struct {
+	ClaimID      string `json:"claimId"`
+	CnsnsStatus  string `json:"cnsnsStatus"`
+	Deadline     string `json:"deadline"`
+	ExpiredHours int64  `json:"expiredHours"`
+	EscalatedBy  string `json:"escalatedBy"`
+}
+
+//==============================================================================================================================
+//	 retrieve_window - Gets the voting window for new claims from the ledger.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_window(stub shim.ChaincodeStubInterface) (int, error) {
+
+	window := Window_Config{Hours: DEFAULT_WINDOW_HOURS}
+
+	bytes, err := stub.GetState("Consensus_Window")
+	if err != nil {
+		return 0, errors.New("Unable to get Consensus_Window")
+	}
+	if bytes != nil {
+		err = json.Unmarshal(bytes, &window)
+		if err != nil {
+			return 0, errors.New("Corrupt Window_Config record")
+		}
+	}
+	return window.Hours, nil
+}
+
+//==============================================================================================================================
+//	 parse_hours - Parses a voting window, which must be a whole number of hours greater than zero.
+//==============================================================================================================================
+func parse_hours(hours string) (int, error) {
+
+	n, err := strconv.Atoi(hours)
+	if err != nil || n < 1 {
+		return 0, errors.New("Invalid number of hours " + hours)
+	}
+	return n, nil
+}
+
+//==============================================================================================================================
+//	 start_window - Sets the consensus deadline of a claim the number of hours passed after the current transaction.
+//==============================================================================================================================
+func start_window(stub shim.ChaincodeStubInterface, c *Claim, hours int) error {
+
+	now, err := tx_time(stub)
+	if err != nil {
+		return err
+	}
+	c.CnsnsDeadline = now.Add(time.Duration(hours) * time.Hour).Format(time.RFC3339)
+	return nil
+}
+
+//==============================================================================================================================
+//	 window_closed - Returns true if the claim's voting window closed before the time passed. Claims created before
+//					 deadlines were kept have no window and never close.
+//==============================================================================================================================
+func window_closed(c Claim, now time.Time) (bool, error) {
+
+	if c.CnsnsDeadline == "" {
+		return false, nil
+	}
+	deadline, err := time.Parse(time.RFC3339, c.CnsnsDeadline)
+	if err != nil {
+		return false, errors.New("Corrupt consensus deadline on claim " + c.ClaimID)
+	}
+	return now.After(deadline), nil
+}
+
+//==============================================================================================================================
+//	 check_window - Rejects proposals and votes on a claim once its voting window has closed.
+//==============================================================================================================================
+func check_window(stub shim.ChaincodeStubInterface, c Claim) error {
+
+	now, err := tx_time(stub)
+	if err != nil {
+		return err
+	}
+	closed, err := window_closed(c, now)
+	if err != nil {
+		return err
+	}
+	if closed {
+		return errors.New("Voting on claim " + c.ClaimID + " closed at " + c.CnsnsDeadline + ", the claim can only be escalated")
+	}
+	return nil
+}
+
+//=================================================================================================================================
+//	 Deadline Functions
+//=================================================================================================================================
+//	 set_consensus_window - The Admin sets the number of hours new claims are open for voting.
+//=================================================================================================================================
+func (t *SimpleChaincode) set_consensus_window(stub shim.ChaincodeStubInterface, caller string, hours string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can set the consensus window")
+	}
+	n, err := parse_hours(hours)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(Window_Config{Hours: n})
+	if err != nil {
+		return nil, errors.New("Error converting Window_Config record")
+	}
+	err = stub.PutState("Consensus_Window", bytes)
+	if err != nil {
+		return nil, errors.New("Unable to put the state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 set_claim_window - The Admin gives the parties on one claim the number of hours passed from now to reach consensus.
+//=================================================================================================================================
+func (t *SimpleChaincode) set_claim_window(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, hours string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can set the consensus window")
+	}
+	if c.CnsnsStatus == CNSNS_REACHED || c.CnsnsStatus == CNSNS_ARBITRATION {
+		return nil, errors.New("Claim " + claimId + " is " + c.CnsnsStatus + ", voting has finished")
+	}
+	n, err := parse_hours(hours)
+	if err != nil {
+		return nil, err
+	}
+	err = start_window(stub, &c, n)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.save_changes(stub, c)
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 escalate - Once the voting window has closed without consensus any voting party or the Admin can hand the claim to
+//				the Arbiter. An open round is superseded.
+//=================================================================================================================================
+func (t *SimpleChaincode) escalate(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string) ([]byte, error) {
+
+	if caller != Admin && caller != Host && caller != Home && caller != CFA {
+		return nil, errors.New("Permission Denied. " + caller + " can not escalate a claim")
+	}
+	if c.CnsnsStatus == CNSNS_REACHED || c.CnsnsStatus == CNSNS_ARBITRATION {
+		return nil, errors.New("Claim " + claimId + " is " + c.CnsnsStatus + ", it can not be escalated")
+	}
+	now, err := tx_time(stub)
+	if err != nil {
+		return nil, err
+	}
+	closed, err := window_closed(c, now)
+	if err != nil {
+		return nil, err
+	}
+	if !closed {
+		return nil, errors.New("Voting on claim " + claimId + " is open until " + c.CnsnsDeadline)
+	}
+
+	p := current_round(&c)
+	if p != nil && p.Status == ROUND_OPEN {
+		p.Status = ROUND_SUPERSEDED
+	}
+	err = set_cnsns_status(&c, CNSNS_ARBITRATION)
+	if err != nil {
+		return nil, err
+	}
+	c.EscalatedBy = caller
+	c.Escalated = now.Format(time.RFC3339)
+
+	_, err = t.save_changes(stub, c)
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 arbitrate - The Arbiter decides the amounts of an escalated claim. The decision is kept as a final round accepted
+//				 by the Arbiter alone and its amounts are promoted onto the claim.
+//=================================================================================================================================
+func (t *SimpleChaincode) arbitrate(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, approvedAmt string, unpaidAmt string, cnsnsNote string) ([]byte, error) {
+
+	if caller != Arbiter {
+		return nil, errors.New("Permission Denied. Only the Arbiter can decide an escalated claim")
+	}
+	if c.CnsnsStatus != CNSNS_ARBITRATION {
+		return nil, errors.New("Claim " + claimId + " is " + c.CnsnsStatus + ", only an escalated claim can be arbitrated")
+	}
+	if approvedAmt == AMOUNT_UNDEFINED || unpaidAmt == AMOUNT_UNDEFINED {
+		return nil, errors.New("The Arbiter must decide both amounts")
+	}
+	approvedAmt, err := canonical_amount("approved amount", approvedAmt)
+	if err != nil {
+		return nil, err
+	}
+	unpaidAmt, err = canonical_amount("unpaid amount", unpaidAmt)
+	if err != nil {
+		return nil, err
+	}
+	decided, err := tx_timestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Rounds = append(c.Rounds, Proposal{
+		Round:       len(c.Rounds) + 1,
+		Status:      ROUND_ACCEPTED,
+		ApprovedAmt: approvedAmt,
+		UnpaidAmt:   unpaidAmt,
+		CnsnsNote:   cnsnsNote,
+		ProposedBy:  caller,
+		Proposed:    decided,
+		Policy:      Quorum_Policy{Rule: QUORUM_ALL, Parties: []string{Arbiter}},
+		Votes:       []Vote{{Party: caller, Signer: t.signer_identity(stub, caller), Decision: VOTE_APPROVE, Comment: cnsnsNote, Voted: decided}},
+	})
+	c.ApprovedAmt = approvedAmt
+	c.UnpaidAmt = unpaidAmt
+	c.CnsnsNote = cnsnsNote
+	c.AcceptedRound = len(c.Rounds)
+	c.CnsnsSigner = t.signer_identity(stub, caller)
+	c.CnsnsSigned = decided
+	err = set_cnsns_status(&c, CNSNS_REACHED)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.save_changes(stub, c)
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 expired_consensus - Returns every claim whose voting window closed without consensus, longest expired first,
+//						 including claims already escalated. Expiry is measured at asOf (RFC3339) when it is given,
+//						 otherwise at the transaction timestamp.
+//=================================================================================================================================
+func (t *SimpleChaincode) expired_consensus(stub shim.ChaincodeStubInterface, caller string, asOf string) ([]byte, error) {
+
+	if caller != Admin && caller != Arbiter && caller != Host && caller != Home && caller != CFA {
+		return nil, errors.New("Permission Denied. " + caller + " can not view expired claims")
+	}
+
+	var now time.Time
+	var err error
+	if asOf != "" {
+		now, err = time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			return nil, errors.New("Invalid asOf timestamp " + asOf)
+		}
+	} else {
+		now, err = tx_time(stub)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	claimIDs, err := t.retrieve_claim_ids(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []Expired_Claim{}
+	for _, id := range claimIDs.ClaimIDs {
+		c, err := t.retrieve_claim(stub, id)
+		if err != nil {
+			return nil, err
+		}
+		if c.CnsnsStatus == CNSNS_REACHED {
+			continue
+		}
+		closed, err := window_closed(c, now)
+		if err != nil {
+			return nil, err
+		}
+		if !closed {
+			continue
+		}
+		deadline, _ := time.Parse(time.RFC3339, c.CnsnsDeadline)
+		expired = append(expired, Expired_Claim{
+			ClaimID:      c.ClaimID,
+			CnsnsStatus:  c.CnsnsStatus,
+			Deadline:     c.CnsnsDeadline,
+			ExpiredHours: int64(now.Sub(deadline) / time.Hour),
+			EscalatedBy:  c.EscalatedBy,
+		})
+	}
+
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].Deadline < expired[j].Deadline })
+
+	return json.Marshal(expired)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	Document - A supporting document kept off the ledger, such as a medical record or invoice. The claim holds the
+//			   SHA-256 hash of its contents so a copy fetched from URI can be checked against what was attached.
+//==============================================================================================================================
+type Document struct {
+	Name       string `json:"name"`
+	MimeType   string `json:"mimeType"`
+	Hash       string `json:"hash"`
+	URI        string `json:"uri"`
+	UploadedBy string `json:"uploadedBy"`
+	Uploaded   string `json:"uploaded"`
+}
+
+//==============================================================================================================================
+//	Document_Check - The result of verify_document.
+//==============================================================================================================================
+type Document_Check struct {
+	ClaimID  string   `json:"claimId"`
+	Hash     string   `json:"hash"`
+	Verified bool     `json:"verified"`
+	Document Document `json:"document"`
+}
+
+//==============================================================================================================================
+//	 handles_documents - Returns whether a role may attach and verify documents on a claim.
+//==============================================================================================================================
+func handles_documents(caller string) bool {
+	return caller == Host || caller == Home || caller == CFA || caller == Arbiter
+}
+
+//==============================================================================================================================
+//	 parse_document_hash - Checks a hex encoded SHA-256 hash and returns it in lower case.
+//==============================================================================================================================
+func parse_document_hash(hash string) (string, error) {
+
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	b, err := hex.DecodeString(hash)
+	if err != nil || len(b) != 32 {
+		return "", errors.New("Invalid document hash " + hash + ", expecting a hex encoded SHA-256 hash")
+	}
+	return hash, nil
+}
+
+//==============================================================================================================================
+//	 find_document - Returns the document attached to a claim under a name, or nil.
+//==============================================================================================================================
+func find_document(c *Claim, name string) *Document {
+
+	for i := range c.Documents {
+		if c.Documents[i].Name == name {
+			return &c.Documents[i]
+		}
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 new_document - Checks the details of a document to be attached to a claim and returns it in its stored form. A
+//					name already used on the claim is rejected.
+//==============================================================================================================================
+func new_document(c *Claim, name string, mimeType string, hash string, uri string) (Document, error) {
+
+	var d Document
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return d, errors.New("A document name is required")
+	}
+	if find_document(c, name) != nil {
+		return d, errors.New("Claim " + c.ClaimID + " already has a document named " + name)
+	}
+	mediaType, params, err := mime.ParseMediaType(mimeType)
+	if err != nil || !strings.Contains(mediaType, "/") {
+		return d, errors.New("Invalid MIME type " + mimeType + ", expecting type/subtype")
+	}
+	hash, err = parse_document_hash(hash)
+	if err != nil {
+		return d, err
+	}
+	location, err := url.Parse(uri)
+	if err != nil || location.Scheme == "" {
+		return d, errors.New("Invalid document URI " + uri + ", expecting an absolute URI")
+	}
+	return Document{Name: name, MimeType: mime.FormatMediaType(mediaType, params), Hash: hash, URI: location.String()}, nil
+}
+
+//==============================================================================================================================
+//	 check_document - Compares the hash of a copy of a document with the hash attached to the claim under its name.
+//==============================================================================================================================
+func check_document(c Claim, name string, hash string) (Document_Check, error) {
+
+	d := find_document(&c, strings.TrimSpace(name))
+	if d == nil {
+		return Document_Check{}, errors.New("Claim " + c.ClaimID + " has no document named " + name)
+	}
+	hash, err := parse_document_hash(hash)
+	if err != nil {
+		return Document_Check{}, err
+	}
+	return Document_Check{ClaimID: c.ClaimID, Hash: hash, Verified: hash == d.Hash, Document: *d}, nil
+}
+
+//=================================================================================================================================
+//	 Document Functions
+//=================================================================================================================================
+//	 attach_document - Records a supporting document on a claim. Documents are evidence for the negotiation, so one
+//					   attached under a name can not be replaced.
+//=================================================================================================================================
+func (t *SimpleChaincode) attach_document(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, name string, mimeType string, hash string, uri string) ([]byte, error) {
+
+	if !handles_documents(caller) {
+		return nil, errors.New("Permission Denied. " + caller + " can not attach documents")
+	}
+	d, err := new_document(&c, name, mimeType, hash, uri)
+	if err != nil {
+		return nil, err
+	}
+	d.UploadedBy = caller
+	d.Uploaded, err = tx_timestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	c.Documents = append(c.Documents, d)
+
+	_, err = t.save_changes(stub, c)
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 verify_document - Checks the hash of a copy of a document against the hash attached to the claim under its name.
+//=================================================================================================================================
+func (t *SimpleChaincode) verify_document(stub shim.ChaincodeStubInterface, c Claim, caller string, name string, hash string) ([]byte, error) {
+
+	if !handles_documents(caller) && caller != Admin {
+		return nil, errors.New("Permission Denied. " + caller + " can not verify documents")
+	}
+	check, err := check_document(c, name, hash)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(check)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testHash = "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"
+
+func TestParseDocumentHash(t *testing.T) {
+
+	cases := []struct {
+		hash     string
+		expected string
+		valid    bool
+	}{
+		{testHash, strings.ToLower(testHash), true},
+		{" " + strings.ToLower(testHash) + " ", strings.ToLower(testHash), true},
+		{testHash[:62], "", false},
+		{testHash + "00", "", false},
+		{"Z" + testHash[1:], "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		hash, err := parse_document_hash(c.hash)
+		if c.valid && (err != nil || hash != c.expected) {
+			t.Errorf("parse_document_hash(%q) = %q, %v, expected %q", c.hash, hash, err, c.expected)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("parse_document_hash(%q) = %q, expected an error", c.hash, hash)
+		}
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+
+	c := Claim{ClaimID: "C1", Documents: []Document{{Name: "invoice", Hash: strings.ToLower(testHash)}}}
+
+	cases := []struct {
+		name     string
+		mimeType string
+		uri      string
+		err      string
+	}{
+		{"record", "application/pdf", "https://docs.example.com/C1/record.pdf", ""},
+		{" record ", "Text/Plain; Charset=UTF-8", "s3://bucket/C1/record.txt", ""},
+		{"", "application/pdf", "https://docs.example.com/C1/record.pdf", "name is required"},
+		{"invoice", "application/pdf", "https://docs.example.com/C1/invoice.pdf", "already has a document named invoice"},
+		{" invoice", "application/pdf", "https://docs.example.com/C1/invoice.pdf", "already has a document named invoice"},
+		{"record", "pdf", "https://docs.example.com/C1/record.pdf", "Invalid MIME type"},
+		{"record", "application/pdf; =x", "https://docs.example.com/C1/record.pdf", "Invalid MIME type"},
+		{"record", "", "https://docs.example.com/C1/record.pdf", "Invalid MIME type"},
+		{"record", "application/pdf", "/C1/record.pdf", "Invalid document URI"},
+		{"record", "application/pdf", "https://docs.example.com/%zz", "Invalid document URI"},
+	}
+	for _, tc := range cases {
+		d, err := new_document(&c, tc.name, tc.mimeType, testHash, tc.uri)
+		if tc.err == "" {
+			if err != nil || d.Name != "record" || d.Hash != strings.ToLower(testHash) || d.URI != tc.uri {
+				t.Errorf("new_document(%q, %q, %q) = %+v, %v", tc.name, tc.mimeType, tc.uri, d, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("new_document(%q, %q, %q) = %v, expected an error containing %q", tc.name, tc.mimeType, tc.uri, err, tc.err)
+		}
+	}
+
+	d, _ := new_document(&c, "notes", "Text/Plain; Charset=UTF-8", testHash, "s3://bucket/notes.txt")
+	if d.MimeType != "text/plain; charset=UTF-8" {
+		t.Errorf("MIME type stored as %q", d.MimeType)
+	}
+	if _, err := new_document(&c, "invoice", "application/pdf", "bad", "https://docs.example.com/x"); err == nil {
+		t.Error("new_document accepted an invalid hash")
+	}
+}
+
+func TestCheckDocument(t *testing.T) {
+
+	c := Claim{ClaimID: "C1", Documents: []Document{{Name: "invoice", Hash: strings.ToLower(testHash)}}}
+	other := strings.Repeat("0", 64)
+
+	cases := []struct {
+		name     string
+		hash     string
+		verified bool
+		err      string
+	}{
+		{"invoice", testHash, true, ""},
+		{" invoice ", strings.ToLower(testHash), true, ""},
+		{"invoice", other, false, ""},
+		{"record", testHash, false, "has no document named record"},
+		{"invoice", "xyz", false, "Invalid document hash"},
+	}
+	for _, tc := range cases {
+		check, err := check_document(c, tc.name, tc.hash)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("check_document(%q, %q) = %v, expected an error containing %q", tc.name, tc.hash, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || check.Verified != tc.verified || check.Document.Name != "invoice" || check.ClaimID != "C1" {
+			t.Errorf("check_document(%q, %q) = %+v, %v, expected verified %v", tc.name, tc.hash, check, err, tc.verified)
+		}
+	}
+}
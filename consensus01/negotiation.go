@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	Negotiation_History - Every round proposed on a claim in order, as returned by get_rounds.
+//==============================================================================================================================
+type Negotiation_History struct {
+	ClaimID       string     `json:"claimId"`
+	CnsnsStatus   string     `json:"cnsnsStatus"`
+	AcceptedRound int        `json:"acceptedRound"`
+	Rounds        []Proposal `json:"rounds"`
+}
+
+//=================================================================================================================================
+//	 Negotiation Functions
+//=================================================================================================================================
+//	 counter_propose - Any voting party answers the latest round with amounts of its own. An open round is superseded
+//					   and its votes kept with it, and voting starts again on the new round.
+//=================================================================================================================================
+func (t *SimpleChaincode) counter_propose(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, approvedAmt string, unpaidAmt string, cnsnsNote string) ([]byte, error) {
+
+	if c.CnsnsStatus != CNSNS_PROPOSED && c.CnsnsStatus != CNSNS_DISPUTED {
+		return nil, errors.New("Claim " + claimId + " is " + c.CnsnsStatus + ", only a proposed or disputed claim can be countered")
+	}
+	p := current_round(&c)
+	if p == nil {
+		return nil, errors.New("Claim " + claimId + " has no proposal to counter")
+	}
+	policy, err := t.retrieve_policy(stub)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Policy.is_party(caller) && !policy.is_party(caller) {
+		return nil, errors.New("Permission Denied. " + caller + " does not vote on consensus")
+	}
+	if p.Status == ROUND_OPEN {
+		p.Status = ROUND_SUPERSEDED
+	}
+
+	err = t.open_round(stub, &c, caller, approvedAmt, unpaidAmt, cnsnsNote)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.save_changes(stub, c)
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_rounds - Returns every negotiation round of a claim with its votes.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_rounds(stub shim.ChaincodeStubInterface, c Claim) ([]byte, error) {
+
+	history := Negotiation_History{ClaimID: c.ClaimID, CnsnsStatus: c.CnsnsStatus, AcceptedRound: c.AcceptedRound, Rounds: c.Rounds}
+	if history.Rounds == nil {
+		history.Rounds = []Proposal{}
+	}
+	return json.Marshal(history)
+}
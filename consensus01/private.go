@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/privdata"
+)
+
+//==============================================================================================================================
+//	 Private collections - The PHI on a claim is kept out of the shared Claim record and sealed by the privdata package
+//						   under "Private_" + collection + "_" + ClaimID. The Claim only keeps a salted hash of each value.
+//==============================================================================================================================
+const COLLECTION_MEMBER = "memberPHI"
+const COLLECTION_CLINICAL = "clinicalPHI"
+
+//==============================================================================================================================
+//	 privateCollections - The organisations allowed to read each collection. The Arbiter needs the diagnosis and
+//						  procedure to settle a dispute but not who the member is.
+//==============================================================================================================================
+var privateCollections = privdata.Collections{
+	{Name: COLLECTION_MEMBER, Readers: []string{Initiator, Host, Home}},
+	{Name: COLLECTION_CLINICAL, Readers: []string{Initiator, Host, Home, Arbiter}},
+}
+
+//==============================================================================================================================
+//	 private_fields - Returns the private fields of a claim.
+//==============================================================================================================================
+func private_fields(c *Claim) []privdata.Field {
+
+	return []privdata.Field{
+		{Collection: COLLECTION_MEMBER, Name: "memberId", Value: &c.MemberID},
+		{Collection: COLLECTION_MEMBER, Name: "subscriberId", Value: &c.SubscriberID},
+		{Collection: COLLECTION_CLINICAL, Name: "diagCode", Value: &c.DiagCode},
+		{Collection: COLLECTION_CLINICAL, Name: "procedureCode", Value: &c.ProcedureCode},
+	}
+}
+
+//==============================================================================================================================
+//	 seal_private - Moves any private values set on a claim into their collections, replacing each with its hash on the
+//					claim.
+//==============================================================================================================================
+func (t *SimpleChaincode) seal_private(stub shim.ChaincodeStubInterface, c *Claim) error {
+
+	hashes := privdata.Hashes{Salt: c.PrivateSalt, Values: c.PrivateHashes}
+	err := privateCollections.Seal(stub, c.ClaimID, private_fields(c), &hashes)
+	if err != nil {
+		return err
+	}
+	c.PrivateSalt = hashes.Salt
+	c.PrivateHashes = hashes.Values
+	return nil
+}
+
+//==============================================================================================================================
+//	 reveal_private - Fills in the private values of a claim from the collections the caller may read and passed the
+//					  key for, clearing the rest.
+//==============================================================================================================================
+func (t *SimpleChaincode) reveal_private(stub shim.ChaincodeStubInterface, c *Claim, caller string) error {
+
+	return privateCollections.Reveal(stub, c.ClaimID, private_fields(c), caller)
+}
+
+//=================================================================================================================================
+//	 Private Collection Functions
+//=================================================================================================================================
+//	 register_collection_key - The Admin registers the hash of the key shared by the organisations that read a
+//							   collection. It can only be registered once.
+//=================================================================================================================================
+func (t *SimpleChaincode) register_collection_key(stub shim.ChaincodeStubInterface, caller string, collection string, keyHash string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Permission Denied. Only the Admin can register collection keys")
+	}
+	return nil, privateCollections.RegisterKey(stub, collection, keyHash)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+//==============================================================================================================================
+//	Party_Key - The ECDSA public key a party signs its consensus votes with, PEM encoded. Stored under
+//				"PartyKey_" + party. Once a party has a key every vote it casts must be signed.
+//==============================================================================================================================
+type Party_Key struct {
+	Party      string `json:"party"`
+	PublicKey  string `json:"publicKey"`
+	Registered string `json:"registered"`
+}
+
+//==============================================================================================================================
+//	Signed_Values - The claim values a vote signs, with the party casting it and its decision so that an approval can not
+//					be replayed as a rejection or as another party's vote. The canonical form whose SHA-256 hash is
+//					signed is a UTF-8 JSON object with exactly the keys claimId, round, approvedAmt, unpaidAmt,
+//					cnsnsNote, party and decision in that order, no whitespace between tokens and no trailing newline.
+//					round is a decimal integer and the others are strings, escaped as encoding/json escapes them with
+//					HTML escaping turned off: only the quote, backslash, control characters, U+2028 and U+2029 are
+//					escaped and invalid UTF-8 becomes U+FFFD. <, > and & are written as they are, e.g.
+//					{"claimId":"C1","round":2,"approvedAmt":"80.00","unpaidAmt":"20.00","cnsnsNote":"<A&B>",
+//					"party":"user_type2_0","decision":"APPROVE"}
+//==============================================================================================================================
+type Signed_Values struct {
+	ClaimID     string `json:"claimId"`
+	Round       int    `json:"round"`
+	ApprovedAmt string `json:"approvedAmt"`
+	UnpaidAmt   string `json:"unpaidAmt"`
+	CnsnsNote   string `json:"cnsnsNote"`
+	Party       string `json:"party"`
+	Decision    string `json:"decision"`
+}
+
+//==============================================================================================================================
+//	Party_Signature - A signed vote with the canonical values it signs and the key it verifies against.
+//==============================================================================================================================
+type Party_Signature struct {
+	Party     string `json:"party"`
+	Signer    string `json:"signer"`
+	Decision  string `json:"decision"`
+	Voted     string `json:"voted"`
+	Canonical string `json:"canonical"`
+	Digest    string `json:"digest"`
+	Signature string `json:"signature"`
+	PublicKey string `json:"publicKey"`
+}
+
+//==============================================================================================================================
+//	Signature_Bundle - Everything needed to check a round's signed votes off the ledger, as returned by get_signatures.
+//==============================================================================================================================
+type Signature_Bundle struct {
+	ClaimID     string            `json:"claimId"`
+	CnsnsStatus string            `json:"cnsnsStatus"`
+	Round       int               `json:"round"`
+	Signatures  []Party_Signature `json:"signatures"`
+}
+
+//==============================================================================================================================
+//	ecdsa_signature - The ASN.1 DER structure of an ECDSA signature.
+//==============================================================================================================================
+type ecdsa_signature struct {
+	R, S *big.Int
+}
+
+//==============================================================================================================================
+//	 party_key_id - Ledger key holding the public key of a party.
+//==============================================================================================================================
+func party_key_id(party string) string {
+	return "PartyKey_" + party
+}
+
+//==============================================================================================================================
+//	 parse_public_key - Parses a PEM encoded PKIX public key, which must be an ECDSA key.
+//==============================================================================================================================
+func parse_public_key(data string) (*ecdsa.PublicKey, error) {
+
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("Public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("Invalid public key. Error: " + err.Error())
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Public key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+//==============================================================================================================================
+//	 canonical_values - Returns the canonical JSON of a party's vote on a round, as described on Signed_Values, and its
+//						SHA-256 digest.
+//==============================================================================================================================
+func canonical_values(claimId string, p Proposal, party string, decision string) ([]byte, []byte, error) {
+
+	values := Signed_Values{ClaimID: claimId, Round: p.Round, ApprovedAmt: p.ApprovedAmt, UnpaidAmt: p.UnpaidAmt, CnsnsNote: p.CnsnsNote, Party: party, Decision: decision}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // json.Marshal would write <, > and & as \u003c, \u003e and \u0026
+	err := encoder.Encode(values)
+	if err != nil {
+		return nil, nil, errors.New("Error converting Signed_Values record")
+	}
+	canonical := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	digest := sha256.Sum256(canonical)
+	return canonical, digest[:], nil
+}
+
+//==============================================================================================================================
+//	 verify_signature - Checks a base64 DER encoded ECDSA signature over a digest.
+//==============================================================================================================================
+func verify_signature(key *ecdsa.PublicKey, digest []byte, signature string) error {
+
+	der, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("Signature is not base64 encoded")
+	}
+	var sig ecdsa_signature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) != 0 || sig.R == nil || sig.S == nil {
+		return errors.New("Signature is not a DER encoded ECDSA signature")
+	}
+	if !ecdsa.Verify(key, digest, sig.R, sig.S) {
+		return errors.New("Signature does not match the proposed values")
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 retrieve_party_key - Gets a party's public key from the ledger. Returns false if the party has not registered one.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_party_key(stub shim.ChaincodeStubInterface, party string) (Party_Key, bool, error) {
+
+	var k Party_Key
+
+	bytes, err := stub.GetState(party_key_id(party))
+	if err != nil {
+		return k, false, errors.New("Unable to get public key of " + party)
+	}
+	if bytes == nil {
+		return k, false, nil
+	}
+	err = json.Unmarshal(bytes, &k)
+	if err != nil {
+		return k, false, errors.New("Corrupt Party_Key record " + party)
+	}
+	return k, true, nil
+}
+
+//==============================================================================================================================
+//	 check_vote_signature - Verifies the signature on a vote against the voter's registered key and returns the key, to
+//							be kept with the vote. A party without a key may vote unsigned.
+//==============================================================================================================================
+func (t *SimpleChaincode) check_vote_signature(stub shim.ChaincodeStubInterface, claimId string, p Proposal, caller string, decision string, signature string) (string, error) {
+
+	k, ok, err := t.retrieve_party_key(stub, caller)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		if signature != "" {
+			return "", errors.New(caller + " has no registered public key to verify the signature")
+		}
+		return "", nil
+	}
+	if signature == "" {
+		return "", errors.New("Votes of " + caller + " must be signed")
+	}
+	key, err := parse_public_key(k.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	_, digest, err := canonical_values(claimId, p, caller, decision)
+	if err != nil {
+		return "", err
+	}
+	err = verify_signature(key, digest, signature)
+	if err != nil {
+		return "", err
+	}
+	return k.PublicKey, nil
+}
+
+//=================================================================================================================================
+//	 Signature Functions
+//=================================================================================================================================
+//	 register_party_key - The Admin registers the ECDSA public key a voting party signs with, replacing any earlier key.
+//=================================================================================================================================
+func (t *SimpleChaincode) register_party_key(stub shim.ChaincodeStubInterface, caller string, party string, publicKey string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can register public keys")
+	}
+	err := t.verify_role(stub, Admin) // A registered key signs votes for the party, so the Admin's ecert is always checked
+	if err != nil {
+		return nil, err
+	}
+	if party != Host && party != Home && party != CFA {
+		return nil, errors.New("Unknown voting party " + party)
+	}
+	_, err = parse_public_key(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	registered, err := tx_timestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(Party_Key{Party: party, PublicKey: publicKey, Registered: registered})
+	if err != nil {
+		return nil, errors.New("Error converting Party_Key record")
+	}
+	err = stub.PutState(party_key_id(party), bytes)
+	if err != nil {
+		return nil, errors.New("Unable to put the state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_signatures - Returns the signed votes on the accepted round of a claim, or on the latest round before
+//					  consensus, each with the canonical values it signs and the public key needed to verify it.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_signatures(stub shim.ChaincodeStubInterface, c Claim) ([]byte, error) {
+
+	p := current_round(&c)
+	if c.AcceptedRound > 0 && c.AcceptedRound <= len(c.Rounds) {
+		p = &c.Rounds[c.AcceptedRound-1]
+	}
+	if p == nil {
+		return nil, errors.New("Claim " + c.ClaimID + " has no proposal")
+	}
+
+	bundle := Signature_Bundle{
+		ClaimID:     c.ClaimID,
+		CnsnsStatus: c.CnsnsStatus,
+		Round:       p.Round,
+		Signatures:  []Party_Signature{},
+	}
+	for _, v := range p.Votes {
+		if v.Signature == "" {
+			continue
+		}
+		canonical, digest, err := canonical_values(c.ClaimID, *p, v.Party, v.Decision)
+		if err != nil {
+			return nil, err
+		}
+		ps := Party_Signature{Party: v.Party, Signer: v.Signer, Decision: v.Decision, Voted: v.Voted, Canonical: string(canonical), Digest: hex.EncodeToString(digest), Signature: v.Signature, PublicKey: v.PublicKey}
+		bundle.Signatures = append(bundle.Signatures, ps)
+	}
+	return json.Marshal(bundle)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+func TestCanonicalValues(t *testing.T) {
+
+	cases := []struct {
+		note     string
+		expected string
+	}{
+		{"n", `{"claimId":"C1","round":2,"approvedAmt":"80.00","unpaidAmt":"20.00","cnsnsNote":"n","party":"user_type2_0","decision":"APPROVE"}`},
+		{"<A&B>", `{"claimId":"C1","round":2,"approvedAmt":"80.00","unpaidAmt":"20.00","cnsnsNote":"<A&B>","party":"user_type2_0","decision":"APPROVE"}`},
+		{"say \"ok\"\\\n", `{"claimId":"C1","round":2,"approvedAmt":"80.00","unpaidAmt":"20.00","cnsnsNote":"say \"ok\"\\\n","party":"user_type2_0","decision":"APPROVE"}`},
+		{"", `{"claimId":"C1","round":2,"approvedAmt":"80.00","unpaidAmt":"20.00","cnsnsNote":"","party":"user_type2_0","decision":"APPROVE"}`},
+	}
+	for _, c := range cases {
+		p := Proposal{Round: 2, ApprovedAmt: "80.00", UnpaidAmt: "20.00", CnsnsNote: c.note}
+		canonical, digest, err := canonical_values("C1", p, Host, VOTE_APPROVE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(canonical) != c.expected {
+			t.Errorf("canonical_values(%q) = %s, expected %s", c.note, canonical, c.expected)
+		}
+		sum := sha256.Sum256([]byte(c.expected))
+		if string(digest) != string(sum[:]) {
+			t.Errorf("canonical_values(%q) digest does not match the canonical form", c.note)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := parse_public_key(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Proposal{Round: 1, ApprovedAmt: "80.00", UnpaidAmt: "20.00", CnsnsNote: "n"}
+	sign := func(k *ecdsa.PrivateKey, p Proposal, party string, decision string) string {
+		_, digest, err := canonical_values("C1", p, party, decision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := asn1.Marshal(ecdsa_signature{R: r, S: s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(der)
+	}
+	tampered := p
+	tampered.ApprovedAmt = "90.00"
+
+	cases := []struct {
+		name      string
+		signature string
+		valid     bool
+	}{
+		{"signed approval", sign(key, p, Host, VOTE_APPROVE), true},
+		{"tampered amount", sign(key, tampered, Host, VOTE_APPROVE), false},
+		{"other decision", sign(key, p, Host, VOTE_REJECT), false},
+		{"other party", sign(key, p, Home, VOTE_APPROVE), false},
+		{"wrong key", sign(other, p, Host, VOTE_APPROVE), false},
+		{"not base64", "%%%", false},
+		{"not DER", base64.StdEncoding.EncodeToString([]byte("signature")), false},
+	}
+	_, digest, err := canonical_values("C1", p, Host, VOTE_APPROVE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		err := verify_signature(public, digest, c.signature)
+		if (err == nil) != c.valid {
+			t.Errorf("%s: verify_signature = %v, expected valid %v", c.name, err, c.valid)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	 Consensus status - CnsnsStatus moves from CONSENSUSINITIATED to PROPOSED when a round of amounts is proposed. The
+//						votes then either reach consensus or leave the round DISPUTED, from where a new round can be
+//						proposed. A claim without consensus when its voting window closes can be escalated to ARBITRATION.
+//==============================================================================================================================
+const CNSNS_INITIATED = "CONSENSUSINITIATED"
+const CNSNS_PROPOSED = "PROPOSED"
+const CNSNS_DISPUTED = "DISPUTED"
+const CNSNS_REACHED = "CONSENSUSRCHD"
+const CNSNS_ARBITRATION = "ARBITRATION"
+
+const AMOUNT_UNDEFINED = amount.UNDEFINED
+
+//==============================================================================================================================
+//	 cnsnsTransitions - The statuses a claim can move to from each consensus status. A claim with consensus is final.
+//==============================================================================================================================
+var cnsnsTransitions = map[string][]string{
+	CNSNS_INITIATED:   {CNSNS_PROPOSED, CNSNS_ARBITRATION},
+	CNSNS_PROPOSED:    {CNSNS_PROPOSED, CNSNS_DISPUTED, CNSNS_REACHED, CNSNS_ARBITRATION},
+	CNSNS_DISPUTED:    {CNSNS_PROPOSED, CNSNS_ARBITRATION},
+	CNSNS_ARBITRATION: {CNSNS_REACHED},
+	CNSNS_REACHED:     {},
+}
+
+//==============================================================================================================================
+//	 cnsnsOwners - The roles allowed to set each consensus field through update_by_host and update_by_home.
+//==============================================================================================================================
+var cnsnsOwners = map[string][]string{
+	"approvedAmt": {Host},
+	"unpaidAmt":   {Home},
+	"cnsnsNote":   {Host, Home},
+}
+
+//==============================================================================================================================
+//	 check_cnsns_status - Rejects a CnsnsStatus that is not one of the consensus statuses.
+//==============================================================================================================================
+func check_cnsns_status(status string) error {
+
+	_, ok := cnsnsTransitions[status]
+	if !ok {
+		return errors.New("Invalid consensus status " + status)
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 set_cnsns_status - Moves a claim to a new consensus status if its current status allows it. The caller still needs
+//						to save the claim.
+//==============================================================================================================================
+func set_cnsns_status(c *Claim, status string) error {
+
+	err := check_cnsns_status(status)
+	if err != nil {
+		return err
+	}
+	for _, next := range cnsnsTransitions[c.CnsnsStatus] {
+		if next == status {
+			c.CnsnsStatus = status
+			return nil
+		}
+	}
+	return errors.New("Claim " + c.ClaimID + " can not move from " + c.CnsnsStatus + " to " + status)
+}
+
+//==============================================================================================================================
+//	 owns_field - Returns true if the role passed may set the consensus field.
+//==============================================================================================================================
+func owns_field(role string, field string) bool {
+
+	for _, owner := range cnsnsOwners[field] {
+		if owner == role {
+			return true
+		}
+	}
+	return false
+}
+
+//==============================================================================================================================
+//	 owned_value - Returns the value a party leaves on a consensus field. A value passed for a field the party does not
+//				   own must be empty or the current value.
+//==============================================================================================================================
+func owned_value(role string, field string, current string, value string) (string, error) {
+
+	if value == "" || value == current {
+		return current, nil
+	}
+	if !owns_field(role, field) {
+		return "", errors.New("Permission Denied. " + role + " can not set " + field)
+	}
+	return value, nil
+}
+
+//==============================================================================================================================
+//	 signer_identity - Returns the identity signing for a party: the username in the caller's certificate when it has
+//					   one, otherwise the role passed in.
+//==============================================================================================================================
+func (t *SimpleChaincode) signer_identity(stub shim.ChaincodeStubInterface, caller string) string {
+
+	username, err := t.get_username(stub)
+	if err != nil || username == "" {
+		return caller
+	}
+	return username
+}
+
+//=================================================================================================================================
+//	 update_consensus - Opens a new round from the latest one with the fields the caller owns changed. An open round is
+//						superseded. Voting waits until both amounts have been filled in by their owners.
+//=================================================================================================================================
+func (t *SimpleChaincode) update_consensus(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, approvedAmt string, unpaidAmt string, cnsnsNote string) ([]byte, error) {
+
+	if c.CnsnsStatus != CNSNS_INITIATED && c.CnsnsStatus != CNSNS_PROPOSED && c.CnsnsStatus != CNSNS_DISPUTED {
+		return nil, errors.New("Claim " + claimId + " is " + c.CnsnsStatus + ", its consensus can no longer be updated")
+	}
+
+	current := Proposal{ApprovedAmt: c.ApprovedAmt, UnpaidAmt: c.UnpaidAmt, CnsnsNote: c.CnsnsNote}
+	p := current_round(&c)
+	if p != nil {
+		current = *p
+	}
+	var err error
+	if approvedAmt != "" && approvedAmt != current.ApprovedAmt {
+		approvedAmt, err = canonical_amount("approved amount", approvedAmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if unpaidAmt != "" && unpaidAmt != current.UnpaidAmt {
+		unpaidAmt, err = canonical_amount("unpaid amount", unpaidAmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	approvedAmt, err = owned_value(caller, "approvedAmt", current.ApprovedAmt, approvedAmt)
+	if err != nil {
+		return nil, err
+	}
+	unpaidAmt, err = owned_value(caller, "unpaidAmt", current.UnpaidAmt, unpaidAmt)
+	if err != nil {
+		return nil, err
+	}
+	cnsnsNote, err = owned_value(caller, "cnsnsNote", current.CnsnsNote, cnsnsNote)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil && approvedAmt == p.ApprovedAmt && unpaidAmt == p.UnpaidAmt && cnsnsNote == p.CnsnsNote {
+		return nil, errors.New("No change to the consensus of claim " + claimId)
+	}
+
+	if p != nil && p.Status == ROUND_OPEN {
+		p.Status = ROUND_SUPERSEDED
+	}
+	err = t.open_round(stub, &c, caller, approvedAmt, unpaidAmt, cnsnsNote)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.save_changes(stub, c)
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	 Quorum rules - ALL needs every voting party to approve, MAJORITY more than half of them and KOFN the number of
+//					approvals set in the policy.
+//==============================================================================================================================
+const QUORUM_ALL = "ALL"
+const QUORUM_MAJORITY = "MAJORITY"
+const QUORUM_K_OF_N = "KOFN"
+
+const VOTE_APPROVE = "APPROVE"
+const VOTE_REJECT = "REJECT"
+
+//==============================================================================================================================
+//	 Round status - Only the latest round of a claim is OPEN. A round closes as ACCEPTED when it reaches consensus,
+//					REJECTED when it can no longer reach it and SUPERSEDED when a party counter-proposes or the claim is
+//					escalated.
+//==============================================================================================================================
+const ROUND_OPEN = "OPEN"
+const ROUND_ACCEPTED = "ACCEPTED"
+const ROUND_REJECTED = "REJECTED"
+const ROUND_SUPERSEDED = "SUPERSEDED"
+
+//==============================================================================================================================
+//	Quorum_Policy - The parties whose votes count and the rule deciding how many approvals reach consensus. Stored on
+//					the ledger under "Quorum_Policy". Without one every vote of Host and Home is needed.
+//==============================================================================================================================
+type Quorum_Policy struct {
+	Rule     string   `json:"rule"`
+	Required int      `json:"required"`
+	Parties  []string `json:"parties"`
+}
+
+//==============================================================================================================================
+//	Vote - A party's decision on the open proposal of a claim. A signed vote keeps the public key it was verified
+//		   against so the signature can still be checked after the party's key is replaced.
+//==============================================================================================================================
+type Vote struct {
+	Party     string `json:"party"`
+	Signer    string `json:"signer"`
+	Decision  string `json:"decision"`
+	Comment   string `json:"comment"`
+	Voted     string `json:"voted"`
+	Signature string `json:"signature,omitempty"`
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+//==============================================================================================================================
+//	Proposal - One negotiation round: the amounts proposed and the votes cast on them. Rounds are numbered from 1 and
+//			   kept on the claim once closed. The policy in force when the round opened is kept with it so changing the
+//			   policy does not affect a vote already under way.
+//==============================================================================================================================
+type Proposal struct {
+	Round       int           `json:"round"`
+	Status      string        `json:"status"`
+	ApprovedAmt string        `json:"approvedAmt"`
+	UnpaidAmt   string        `json:"unpaidAmt"`
+	CnsnsNote   string        `json:"cnsnsNote"`
+	ProposedBy  string        `json:"proposedBy"`
+	Proposed    string        `json:"proposed"`
+	Policy      Quorum_Policy `json:"policy"`
+	Votes       []Vote        `json:"votes"`
+}
+
+//==============================================================================================================================
+//	Vote_Tally - The state of voting on a claim's latest round, as returned by get_votes.
+//==============================================================================================================================
+type Vote_Tally struct {
+	ClaimID     string    `json:"claimId"`
+	CnsnsStatus string    `json:"cnsnsStatus"`
+	Proposal    *Proposal `json:"proposal"`
+	Approvals   int       `json:"approvals"`
+	Rejections  int       `json:"rejections"`
+	Needed      int       `json:"needed"`
+	Pending     []string  `json:"pending"`
+}
+
+//==============================================================================================================================
+//	 tx_time - Returns the timestamp of the current transaction in UTC. Every peer sees the same value so it is safe
+//			   to store on the ledger, unlike the local clock.
+//==============================================================================================================================
+func tx_time(stub shim.ChaincodeStubInterface) (time.Time, error) {
+
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New("Couldn't get transaction timestamp. Error: " + err.Error())
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+//==============================================================================================================================
+//	 tx_timestamp - Returns the timestamp of the current transaction in RFC3339 format.
+//==============================================================================================================================
+func tx_timestamp(stub shim.ChaincodeStubInterface) (string, error) {
+
+	ts, err := tx_time(stub)
+	if err != nil {
+		return "", err
+	}
+	return ts.Format(time.RFC3339), nil
+}
+
+//==============================================================================================================================
+//	 canonical_amount - Returns an amount proposed for a claim with the two decimal places Amount.String writes, so
+//						that equal amounts are stored and signed alike. The amount must be a non-negative decimal. An
+//						amount not yet filled in by the party that owns it is UNDEFINED.
+//==============================================================================================================================
+func canonical_amount(name string, value string) (string, error) {
+
+	if value == AMOUNT_UNDEFINED {
+		return value, nil
+	}
+	a, err := amount.ParseRounded(value)
+	if err != nil || strings.TrimSpace(value) == "" || strings.HasPrefix(value, "-") {
+		return "", errors.New("Invalid " + name + " " + value)
+	}
+	return a.String(), nil
+}
+
+//==============================================================================================================================
+//	 required_votes - Returns the number of approvals a policy needs to reach consensus.
+//==============================================================================================================================
+func (p Quorum_Policy) required_votes() int {
+
+	switch p.Rule {
+	case QUORUM_MAJORITY:
+		return len(p.Parties)/2 + 1
+	case QUORUM_K_OF_N:
+		return p.Required
+	}
+	return len(p.Parties)
+}
+
+//==============================================================================================================================
+//	 is_party - Returns true if the role passed votes under the policy.
+//==============================================================================================================================
+func (p Quorum_Policy) is_party(role string) bool {
+
+	for _, party := range p.Parties {
+		if party == role {
+			return true
+		}
+	}
+	return false
+}
+
+//==============================================================================================================================
+//	 retrieve_policy - Gets the quorum policy from the ledger, defaulting to unanimous Host and Home approval.
+//==============================================================================================================================
+func (t *SimpleChaincode) retrieve_policy(stub shim.ChaincodeStubInterface) (Quorum_Policy, error) {
+
+	policy := Quorum_Policy{Rule: QUORUM_ALL, Parties: []string{Host, Home}}
+
+	bytes, err := stub.GetState("Quorum_Policy")
+	if err != nil {
+		return policy, errors.New("Unable to get Quorum_Policy")
+	}
+	if bytes == nil {
+		return policy, nil
+	}
+	err = json.Unmarshal(bytes, &policy)
+	if err != nil {
+		return policy, errors.New("Corrupt Quorum_Policy record")
+	}
+	return policy, nil
+}
+
+//==============================================================================================================================
+//	 current_round - Returns the latest negotiation round of a claim, or nil before anything is proposed.
+//==============================================================================================================================
+func current_round(c *Claim) *Proposal {
+
+	if len(c.Rounds) == 0 {
+		return nil
+	}
+	return &c.Rounds[len(c.Rounds)-1]
+}
+
+//==============================================================================================================================
+//	 open_round - Adds a new round proposing the amounts passed, voted on under the current quorum policy. The caller
+//				  closes any open round first and saves the claim.
+//==============================================================================================================================
+func (t *SimpleChaincode) open_round(stub shim.ChaincodeStubInterface, c *Claim, caller string, approvedAmt string, unpaidAmt string, cnsnsNote string) error {
+
+	err := check_window(stub, *c)
+	if err != nil {
+		return err
+	}
+	approvedAmt, err = canonical_amount("approved amount", approvedAmt)
+	if err != nil {
+		return err
+	}
+	unpaidAmt, err = canonical_amount("unpaid amount", unpaidAmt)
+	if err != nil {
+		return err
+	}
+
+	policy, err := t.retrieve_policy(stub)
+	if err != nil {
+		return err
+	}
+	proposed, err := tx_timestamp(stub)
+	if err != nil {
+		return err
+	}
+	c.Rounds = append(c.Rounds, Proposal{
+		Round:       len(c.Rounds) + 1,
+		Status:      ROUND_OPEN,
+		ApprovedAmt: approvedAmt,
+		UnpaidAmt:   unpaidAmt,
+		CnsnsNote:   cnsnsNote,
+		ProposedBy:  caller,
+		Proposed:    proposed,
+		Policy:      policy,
+		Votes:       []Vote{},
+	})
+	return set_cnsns_status(c, CNSNS_PROPOSED)
+}
+
+//==============================================================================================================================
+//	 tally_votes - Counts the votes cast on a proposal and lists the parties still to vote.
+//==============================================================================================================================
+func tally_votes(p Proposal) (int, int, []string) {
+
+	approvals, rejections := 0, 0
+	voted := make(map[string]bool)
+	for _, v := range p.Votes {
+		voted[v.Party] = true
+		if v.Decision == VOTE_APPROVE {
+			approvals++
+		} else {
+			rejections++
+		}
+	}
+	pending := []string{}
+	for _, party := range p.Policy.Parties {
+		if !voted[party] {
+			pending = append(pending, party)
+		}
+	}
+	return approvals, rejections, pending
+}
+
+//=================================================================================================================================
+//	 Consensus Functions
+//=================================================================================================================================
+//	 set_quorum_policy - The Admin sets the quorum rule and whether the CFA votes alongside Host and Home. The required
+//						 number of approvals is only used by the KOFN rule.
+//=================================================================================================================================
+func (t *SimpleChaincode) set_quorum_policy(stub shim.ChaincodeStubInterface, caller string, rule string, required string, includeCFA string) ([]byte, error) {
+
+	if caller != Admin {
+		return nil, errors.New("Only the Admin can set the quorum policy")
+	}
+
+	policy := Quorum_Policy{Rule: rule, Parties: []string{Host, Home}}
+	if includeCFA == "true" {
+		policy.Parties = append(policy.Parties, CFA)
+	} else if includeCFA != "false" {
+		return nil, errors.New("Invalid CFA flag " + includeCFA + ", expecting true or false")
+	}
+
+	switch rule {
+	case QUORUM_ALL, QUORUM_MAJORITY:
+	case QUORUM_K_OF_N:
+		k, err := strconv.Atoi(required)
+		if err != nil || k < 1 || k > len(policy.Parties) {
+			return nil, errors.New("Invalid number of required votes " + required + " for " + strconv.Itoa(len(policy.Parties)) + " parties")
+		}
+		policy.Required = k
+	default:
+		return nil, errors.New("Unknown quorum rule " + rule)
+	}
+
+	bytes, err := json.Marshal(policy)
+	if err != nil {
+		return nil, errors.New("Error converting Quorum_Policy record")
+	}
+	err = stub.PutState("Quorum_Policy", bytes)
+	if err != nil {
+		return nil, errors.New("Unable to put the state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 propose_consensus - The Host proposes the amounts for a claim, opening the first negotiation round or a new one
+//						 after the last was rejected.
+//=================================================================================================================================
+func (t *SimpleChaincode) propose_consensus(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, approvedAmt string, unpaidAmt string, cnsnsNote string) ([]byte, error) {
+
+	if caller != Host {
+		return nil, errors.New("Permission Denied. Only the Host can propose consensus amounts")
+	}
+	if c.CnsnsStatus != CNSNS_INITIATED && c.CnsnsStatus != CNSNS_DISPUTED {
+		return nil, errors.New("Claim " + claimId + " is " + c.CnsnsStatus + ", a proposal can only be made on an initiated or disputed claim")
+	}
+	err := t.open_round(stub, &c, caller, approvedAmt, unpaidAmt, cnsnsNote)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.save_changes(stub, c)
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 vote_consensus - Records a party's approve or reject vote on the open round. Once enough parties approve the round
+//					  is accepted and its amounts are promoted onto the claim. If the parties left to vote can no
+//					  longer make up the quorum the round is rejected and the claim disputed. A party with a registered
+//					  key signs the round's values, see get_signatures.
+//=================================================================================================================================
+func (t *SimpleChaincode) vote_consensus(stub shim.ChaincodeStubInterface, claimId string, c Claim, caller string, decision string, comment string, signature string) ([]byte, error) {
+
+	p := current_round(&c)
+	if c.CnsnsStatus != CNSNS_PROPOSED || p == nil || p.Status != ROUND_OPEN {
+		return nil, errors.New("Claim " + claimId + " has no open proposal")
+	}
+	if !p.Policy.is_party(caller) {
+		return nil, errors.New("Permission Denied. " + caller + " does not vote on consensus")
+	}
+	if decision != VOTE_APPROVE && decision != VOTE_REJECT {
+		return nil, errors.New("Invalid vote " + decision + ", expecting APPROVE or REJECT")
+	}
+	for _, v := range p.Votes {
+		if v.Party == caller {
+			return nil, errors.New(caller + " has already voted on claim " + claimId)
+		}
+	}
+	err := check_window(stub, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.ApprovedAmt == AMOUNT_UNDEFINED || p.UnpaidAmt == AMOUNT_UNDEFINED {
+		return nil, errors.New("Round " + strconv.Itoa(p.Round) + " of claim " + claimId + " is waiting for both amounts before it can be voted on")
+	}
+
+	publicKey, err := t.check_vote_signature(stub, claimId, *p, caller, decision, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	voted, err := tx_timestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+	signer := t.signer_identity(stub, caller)
+	p.Votes = append(p.Votes, Vote{Party: caller, Signer: signer, Decision: decision, Comment: comment, Voted: voted, Signature: signature, PublicKey: publicKey})
+
+	approvals, _, pending := tally_votes(*p)
+	needed := p.Policy.required_votes()
+	if approvals >= needed {
+		c.ApprovedAmt = p.ApprovedAmt
+		c.UnpaidAmt = p.UnpaidAmt
+		c.CnsnsNote = p.CnsnsNote
+		c.AcceptedRound = p.Round
+		c.CnsnsSigner = signer
+		c.CnsnsSigned = voted
+		err = set_cnsns_status(&c, CNSNS_REACHED)
+		p.Status = ROUND_ACCEPTED
+	} else if approvals+len(pending) < needed {
+		err = set_cnsns_status(&c, CNSNS_DISPUTED)
+		p.Status = ROUND_REJECTED
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.save_changes(stub, c)
+	if err != nil {
+		return nil, errors.New("Not able to save state")
+	}
+	return nil, nil
+}
+
+//=================================================================================================================================
+//	 get_votes - Returns the latest round on a claim with the votes cast so far and the parties still to vote.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_votes(stub shim.ChaincodeStubInterface, c Claim) ([]byte, error) {
+
+	p := current_round(&c)
+	tally := Vote_Tally{ClaimID: c.ClaimID, CnsnsStatus: c.CnsnsStatus, Proposal: p, Pending: []string{}}
+	if p != nil {
+		tally.Approvals, tally.Rejections, tally.Pending = tally_votes(*p)
+		tally.Needed = p.Policy.required_votes()
+	}
+	return json.Marshal(tally)
+}
+
+//=================================================================================================================================
+//	 get_quorum_policy - Returns the quorum policy new proposals are voted under.
+//=================================================================================================================================
+func (t *SimpleChaincode) get_quorum_policy(stub shim.ChaincodeStubInterface) ([]byte, error) {
+
+	policy, err := t.retrieve_policy(stub)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(policy)
+}
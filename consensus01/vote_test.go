@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequiredVotes(t *testing.T) {
+
+	cases := []struct {
+		policy   Quorum_Policy
+		expected int
+	}{
+		{Quorum_Policy{Rule: QUORUM_ALL, Parties: []string{Host, Home}}, 2},
+		{Quorum_Policy{Rule: QUORUM_ALL, Parties: []string{Host, Home, CFA}}, 3},
+		{Quorum_Policy{Rule: QUORUM_MAJORITY, Parties: []string{Host, Home}}, 2},
+		{Quorum_Policy{Rule: QUORUM_MAJORITY, Parties: []string{Host, Home, CFA}}, 2},
+		{Quorum_Policy{Rule: QUORUM_MAJORITY, Parties: []string{Host}}, 1},
+		{Quorum_Policy{Rule: QUORUM_K_OF_N, Required: 1, Parties: []string{Host, Home, CFA}}, 1},
+		{Quorum_Policy{Rule: QUORUM_K_OF_N, Required: 3, Parties: []string{Host, Home, CFA}}, 3},
+		{Quorum_Policy{Parties: []string{Host, Home}}, 2},
+	}
+	for _, c := range cases {
+		if n := c.policy.required_votes(); n != c.expected {
+			t.Errorf("required_votes(%s of %v) = %d, expected %d", c.policy.Rule, c.policy.Parties, n, c.expected)
+		}
+	}
+}
+
+func TestTallyVotes(t *testing.T) {
+
+	parties := Quorum_Policy{Rule: QUORUM_ALL, Parties: []string{Host, Home, CFA}}
+
+	cases := []struct {
+		name       string
+		votes      []Vote
+		approvals  int
+		rejections int
+		pending    []string
+	}{
+		{"no votes", nil, 0, 0, []string{Host, Home, CFA}},
+		{"one approval", []Vote{{Party: Host, Decision: VOTE_APPROVE}}, 1, 0, []string{Home, CFA}},
+		{"approve and reject", []Vote{{Party: Home, Decision: VOTE_REJECT}, {Party: Host, Decision: VOTE_APPROVE}}, 1, 1, []string{CFA}},
+		{"all voted", []Vote{{Party: Host, Decision: VOTE_APPROVE}, {Party: Home, Decision: VOTE_APPROVE}, {Party: CFA, Decision: VOTE_REJECT}}, 2, 1, []string{}},
+	}
+	for _, c := range cases {
+		approvals, rejections, pending := tally_votes(Proposal{Policy: parties, Votes: c.votes})
+		if approvals != c.approvals || rejections != c.rejections || !reflect.DeepEqual(pending, c.pending) {
+			t.Errorf("%s: tally_votes = %d, %d, %v, expected %d, %d, %v", c.name, approvals, rejections, pending, c.approvals, c.rejections, c.pending)
+		}
+	}
+}
+
+func TestCanonicalAmount(t *testing.T) {
+
+	cases := []struct {
+		value    string
+		expected string
+		valid    bool
+	}{
+		{"80", "80.00", true},
+		{"80.5", "80.50", true},
+		{"80.005", "80.01", true},
+		{".5", "0.50", true},
+		{"0", "0.00", true},
+		{AMOUNT_UNDEFINED, AMOUNT_UNDEFINED, true},
+		{"-1", "", false},
+		{"-0", "", false},
+		{"NaN", "", false},
+		{"Inf", "", false},
+		{"1e3", "", false},
+		{"0x1p4", "", false},
+		{"", "", false},
+		{" ", "", false},
+	}
+	for _, c := range cases {
+		amount, err := canonical_amount("amount", c.value)
+		if c.valid && (err != nil || amount != c.expected) {
+			t.Errorf("canonical_amount(%q) = %q, %v, expected %q", c.value, amount, err, c.expected)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("canonical_amount(%q) = %q, expected an error", c.value, amount)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+// Package privdata keeps the PHI on a chaincode's records out of the shared ledger state. The claimTransfer01 and
+// consensus01 chaincodes declare their collections and which roles read them, and this package seals and reveals the
+// values. Each collection's fields are encrypted with that collection's key and stored under
+// "Private_" + collection + "_" + record ID, and the record itself only keeps a salted hash of each value. The shim has
+// no private data or transient data, so callers pass the keys they hold in the transaction metadata as a JSON object of
+// collection name to base64 key, and the world state only keeps the SHA-256 hash of each key.
+//
+// This keeps PHI out of the world state, not off the ledger. Fabric v0.6 stores each transaction in its block with its
+// arguments and metadata, so the plaintext values passed to create a claim and the collection keys passed with them can
+// be read by anyone holding the blockchain. Deploy on a network whose peers are trusted with the PHI, or have clients
+// pass PHI already encrypted.
+package privdata
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const KEY_SIZE = 32
+
+//==============================================================================================================================
+//	Collection - A set of private fields encrypted under one key and the roles that may read them.
+//==============================================================================================================================
+type Collection struct {
+	Name    string
+	Readers []string
+}
+
+//==============================================================================================================================
+//	Collections - The private collections of a chaincode.
+//==============================================================================================================================
+type Collections []Collection
+
+//==============================================================================================================================
+//	Field - A private value on a record. Name identifies it within the record's sealed values and hashes.
+//==============================================================================================================================
+type Field struct {
+	Collection string
+	Name       string
+	Value      *string
+}
+
+//==============================================================================================================================
+//	Hashes - The salt and salted hashes a record keeps in place of its private values.
+//==============================================================================================================================
+type Hashes struct {
+	Salt   string
+	Values map[string]string
+}
+
+//==============================================================================================================================
+//	CollectionKey - The hash of the key registered for a collection. Stored under "CollectionKey_" + collection.
+//==============================================================================================================================
+type CollectionKey struct {
+	Collection string `json:"collection"`
+	KeyHash    string `json:"keyHash"`
+}
+
+//==============================================================================================================================
+//	SealedCollection - The encrypted values of one collection for one record. ClaimID holds the record ID, keeping the
+//					   JSON of records sealed before other records were kept private.
+//==============================================================================================================================
+type SealedCollection struct {
+	ClaimID    string `json:"claimId"`
+	Collection string `json:"collection"`
+	Nonce      string `json:"nonce"`
+	Data       string `json:"data"`
+}
+
+//==============================================================================================================================
+//	 Find - Returns the collection with the name passed in, or nil.
+//==============================================================================================================================
+func (cs Collections) Find(name string) *Collection {
+
+	for i := range cs {
+		if cs[i].Name == name {
+			return &cs[i]
+		}
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 CanRead - Returns whether a role may read the collection.
+//==============================================================================================================================
+func (p Collection) CanRead(role string) bool {
+
+	for _, r := range p.Readers {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+//==============================================================================================================================
+//	 collection_key_key - Ledger key holding the key hash registered for a collection.
+//==============================================================================================================================
+func collection_key_key(collection string) string {
+	return "CollectionKey_" + collection
+}
+
+//==============================================================================================================================
+//	 sealed_key - Ledger key holding a record's encrypted values for a collection.
+//==============================================================================================================================
+func sealed_key(collection string, id string) string {
+	return "Private_" + collection + "_" + id
+}
+
+//==============================================================================================================================
+//	 KeyHash - Returns the hex SHA-256 hash a collection key is registered under.
+//==============================================================================================================================
+func KeyHash(key []byte) string {
+
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+//==============================================================================================================================
+//	 retrieve_collection_key - Gets the key hash registered for a collection. Returns false if none has been registered.
+//==============================================================================================================================
+func retrieve_collection_key(stub shim.ChaincodeStubInterface, collection string) (CollectionKey, bool, error) {
+
+	var k CollectionKey
+
+	bytes, err := stub.GetState(collection_key_key(collection))
+	if err != nil {
+		return k, false, errors.New("Unable to get the key for private collection " + collection)
+	}
+	if bytes == nil {
+		return k, false, nil
+	}
+	err = json.Unmarshal(bytes, &k)
+	if err != nil {
+		return k, false, errors.New("Corrupt CollectionKey record " + collection)
+	}
+	return k, true, nil
+}
+
+//==============================================================================================================================
+//	 Key - Returns the caller's key for a collection from the transaction metadata, checked against the registered
+//		   hash. Returns nil if the caller did not pass a key for the collection.
+//==============================================================================================================================
+func Key(stub shim.ChaincodeStubInterface, collection string) ([]byte, error) {
+
+	metadata, err := stub.GetCallerMetadata()
+	if err != nil {
+		return nil, errors.New("Couldn't get caller metadata. Error: " + err.Error())
+	}
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	var keys map[string]string
+	err = json.Unmarshal(metadata, &keys)
+	if err != nil {
+		return nil, errors.New("Caller metadata must be a JSON object of private collection keys")
+	}
+	encoded, found := keys[collection]
+	if !found {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != KEY_SIZE {
+		return nil, errors.New("Invalid key for private collection " + collection + ", expecting 32 base64 encoded bytes")
+	}
+	registered, found, err := retrieve_collection_key(stub, collection)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("Private collection " + collection + " has no registered key")
+	}
+	if !hmac.Equal([]byte(KeyHash(key)), []byte(registered.KeyHash)) {
+		return nil, errors.New("The key passed for private collection " + collection + " is not the registered key")
+	}
+	return key, nil
+}
+
+//==============================================================================================================================
+//	 RequireKey - Returns the caller's key for a collection, which must have been passed.
+//==============================================================================================================================
+func RequireKey(stub shim.ChaincodeStubInterface, collection string) ([]byte, error) {
+
+	key, err := Key(stub, collection)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("The key for private collection " + collection + " was not passed")
+	}
+	return key, nil
+}
+
+//==============================================================================================================================
+//	 Hash - Hashes a private value under the collection key, salted with the record's salt and the field name. The key
+//			stops values being recovered by hashing every possible code or member and the salt stops the same member
+//			or diagnosis being matched across records.
+//==============================================================================================================================
+func Hash(key []byte, salt string, name string, value string) string {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(salt + "|" + name + "|" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//==============================================================================================================================
+//	 salt - Returns the salt for a record's private hashes. Every peer derives the same salt from the transaction that
+//			first sealed the record.
+//==============================================================================================================================
+func salt(stub shim.ChaincodeStubInterface, id string) string {
+
+	sum := sha256.Sum256([]byte(stub.GetTxID() + "|" + id))
+	return hex.EncodeToString(sum[:16])
+}
+
+//==============================================================================================================================
+//	 collection_cipher - Returns the AES-GCM cipher for a collection key.
+//==============================================================================================================================
+func collection_cipher(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New("Invalid private collection key")
+	}
+	return cipher.NewGCM(block)
+}
+
+//==============================================================================================================================
+//	 seal_values - Encrypts a record's values for a collection. The nonce is derived from the key and the values rather
+//				   than drawn at random so that every peer writes the same record.
+//==============================================================================================================================
+func seal_values(key []byte, id string, collection string, values map[string]string) (SealedCollection, error) {
+
+	sealed := SealedCollection{ClaimID: id, Collection: collection}
+
+	plaintext, err := json.Marshal(values)
+	if err != nil {
+		return sealed, errors.New("Error converting private collection " + collection)
+	}
+	aead, err := collection_cipher(key)
+	if err != nil {
+		return sealed, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "|" + collection + "|"))
+	mac.Write(plaintext)
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+
+	sealed.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	sealed.Data = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(id+"|"+collection)))
+	return sealed, nil
+}
+
+//==============================================================================================================================
+//	 open_values - Decrypts a record's values for a collection. A record with nothing sealed in the collection returns
+//				   an empty set of values.
+//==============================================================================================================================
+func open_values(stub shim.ChaincodeStubInterface, key []byte, id string, collection string) (map[string]string, error) {
+
+	values := make(map[string]string)
+
+	bytes, err := stub.GetState(sealed_key(collection, id))
+	if err != nil {
+		return nil, errors.New("Unable to get private collection " + collection + " for " + id)
+	}
+	if bytes == nil {
+		return values, nil
+	}
+	var sealed SealedCollection
+	err = json.Unmarshal(bytes, &sealed)
+	if err != nil {
+		return nil, errors.New("Corrupt SealedCollection record " + collection + " for " + id)
+	}
+
+	aead, err := collection_cipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(sealed.Nonce)
+	if err == nil && len(nonce) != aead.NonceSize() {
+		err = errors.New("invalid nonce")
+	}
+	var data, plaintext []byte
+	if err == nil {
+		data, err = base64.StdEncoding.DecodeString(sealed.Data)
+	}
+	if err == nil {
+		plaintext, err = aead.Open(nil, nonce, data, []byte(sealed.ClaimID+"|"+collection))
+	}
+	if err == nil {
+		err = json.Unmarshal(plaintext, &values)
+	}
+	if err != nil {
+		return nil, errors.New("Unable to decrypt private collection " + collection + " for " + id)
+	}
+	return values, nil
+}
+
+//==============================================================================================================================
+//	 Seal - Moves any private values set on a record into their collections, replacing each with its hash. Values
+//			already sealed are kept, so a collection is only rewritten, and its key only needed, when one of its
+//			fields has been given a value. Records written in clear before their collections were introduced are
+//			sealed the next time they are saved.
+//==============================================================================================================================
+func (cs Collections) Seal(stub shim.ChaincodeStubInterface, id string, fields []Field, hashes *Hashes) error {
+
+	for _, p := range cs {
+		var pending []Field
+		for _, f := range fields {
+			if f.Collection == p.Name && *f.Value != "" {
+				pending = append(pending, f)
+			}
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		key, err := RequireKey(stub, p.Name)
+		if err != nil {
+			return err
+		}
+		values, err := open_values(stub, key, id, p.Name)
+		if err != nil {
+			return err
+		}
+		if hashes.Salt == "" {
+			hashes.Salt = salt(stub, id)
+		}
+		if hashes.Values == nil {
+			hashes.Values = make(map[string]string)
+		}
+		for _, f := range pending {
+			values[f.Name] = *f.Value
+			hashes.Values[f.Name] = Hash(key, hashes.Salt, f.Name, *f.Value)
+			*f.Value = ""
+		}
+
+		sealed, err := seal_values(key, id, p.Name, values)
+		if err != nil {
+			return err
+		}
+		bytes, err := json.Marshal(sealed)
+		if err != nil {
+			return errors.New("Error converting SealedCollection record")
+		}
+		err = stub.PutState(sealed_key(p.Name, id), bytes)
+		if err != nil {
+			return errors.New("Unable to put the state")
+		}
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 Reveal - Fills in the private values of a record from the collections the role may read and the caller passed the
+//			  key for. Fields in collections the role may not read are cleared, including any still held in clear on
+//			  records that have not been sealed yet.
+//==============================================================================================================================
+func (cs Collections) Reveal(stub shim.ChaincodeStubInterface, id string, fields []Field, role string) error {
+
+	for _, p := range cs {
+		if !p.CanRead(role) {
+			for _, f := range fields {
+				if f.Collection == p.Name {
+					*f.Value = ""
+				}
+			}
+			continue
+		}
+
+		key, err := Key(stub, p.Name)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			continue
+		}
+		values, err := open_values(stub, key, id, p.Name)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if v, found := values[f.Name]; f.Collection == p.Name && found {
+				*f.Value = v
+			}
+		}
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 Copy - Gives a new record the sealed values of the record it is derived from, and the hashes of the fields they
+//			have in common. The sealed values are copied as they are, so no keys are needed.
+//==============================================================================================================================
+func (cs Collections) Copy(stub shim.ChaincodeStubInterface, fromId string, from Hashes, toId string, fields []Field) (Hashes, error) {
+
+	for _, p := range cs {
+		bytes, err := stub.GetState(sealed_key(p.Name, fromId))
+		if err != nil {
+			return Hashes{}, errors.New("Unable to get private collection " + p.Name + " for " + fromId)
+		}
+		if bytes == nil {
+			continue
+		}
+		err = stub.PutState(sealed_key(p.Name, toId), bytes)
+		if err != nil {
+			return Hashes{}, errors.New("Unable to put the state")
+		}
+	}
+
+	to := Hashes{Salt: from.Salt}
+	for _, f := range fields {
+		if hash, found := from.Values[f.Name]; found {
+			if to.Values == nil {
+				to.Values = make(map[string]string)
+			}
+			to.Values[f.Name] = hash
+		}
+	}
+	return to, nil
+}
+
+//==============================================================================================================================
+//	 RegisterKey - Registers the hash of the key shared by the organisations that read a collection. The key can not be
+//				   changed once values have been sealed with it, so it can only be registered once. The caller checks
+//				   that the Admin is registering it.
+//==============================================================================================================================
+func (cs Collections) RegisterKey(stub shim.ChaincodeStubInterface, collection string, keyHash string) error {
+
+	if cs.Find(collection) == nil {
+		return errors.New("Unknown private collection " + collection)
+	}
+	hash, err := hex.DecodeString(keyHash)
+	if err != nil || len(hash) != sha256.Size {
+		return errors.New("Invalid key hash " + keyHash + ", expecting a hex encoded SHA-256 hash")
+	}
+	_, found, err := retrieve_collection_key(stub, collection)
+	if err != nil {
+		return err
+	}
+	if found {
+		return errors.New("Private collection " + collection + " already has a registered key")
+	}
+
+	bytes, err := json.Marshal(CollectionKey{Collection: collection, KeyHash: hex.EncodeToString(hash)})
+	if err != nil {
+		return errors.New("Error creating CollectionKey record")
+	}
+	err = stub.PutState(collection_key_key(collection), bytes)
+	if err != nil {
+		return errors.New("Unable to put the state")
+	}
+	return nil
+}
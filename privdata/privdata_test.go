@@ -0,0 +1,101 @@
+package privdata
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestCollections(t *testing.T) {
+
+	cs := Collections{
+		{Name: "member", Readers: []string{"Host", "Home"}},
+		{Name: "clinical", Readers: []string{"Host", "Home", "Arbiter"}},
+	}
+
+	cases := []struct {
+		collection string
+		role       string
+		found      bool
+		canRead    bool
+	}{
+		{"member", "Host", true, true},
+		{"member", "Arbiter", true, false},
+		{"clinical", "Arbiter", true, true},
+		{"clinical", "", true, false},
+		{"billing", "Host", false, false},
+	}
+	for _, c := range cases {
+		p := cs.Find(c.collection)
+		if (p != nil) != c.found {
+			t.Errorf("Find(%q) found %v, want %v", c.collection, p != nil, c.found)
+			continue
+		}
+		if p != nil && p.CanRead(c.role) != c.canRead {
+			t.Errorf("%s.CanRead(%q) = %v, want %v", c.collection, c.role, !c.canRead, c.canRead)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+
+	key := bytes.Repeat([]byte{1}, KEY_SIZE)
+	other := bytes.Repeat([]byte{2}, KEY_SIZE)
+	base := Hash(key, "salt", "memberId", "MEM1")
+
+	cases := []struct {
+		name   string
+		hash   string
+		sameAs bool
+	}{
+		{"same inputs", Hash(key, "salt", "memberId", "MEM1"), true},
+		{"other key", Hash(other, "salt", "memberId", "MEM1"), false},
+		{"other salt", Hash(key, "pepper", "memberId", "MEM1"), false},
+		{"other field", Hash(key, "salt", "subscriberId", "MEM1"), false},
+		{"other value", Hash(key, "salt", "memberId", "MEM2"), false},
+		{"separator moved", Hash(key, "salt|memberId", "", "MEM1"), false},
+	}
+	for _, c := range cases {
+		if (c.hash == base) != c.sameAs {
+			t.Errorf("%s: hash equal %v, want %v", c.name, c.hash == base, c.sameAs)
+		}
+	}
+	if len(KeyHash(key)) != 64 || KeyHash(key) == KeyHash(other) {
+		t.Errorf("KeyHash(key) = %q", KeyHash(key))
+	}
+}
+
+func TestSealValues(t *testing.T) {
+
+	key := bytes.Repeat([]byte{7}, KEY_SIZE)
+	values := map[string]string{"memberId": "MEM1", "subscriberId": "SUB1"}
+
+	sealed, err := seal_values(key, "C1", "member", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := seal_values(key, "C1", "member", values)
+	if sealed != again {
+		t.Errorf("sealing the same values twice gave %+v and %+v", sealed, again)
+	}
+	moved, _ := seal_values(key, "C2", "member", values)
+	if moved.Nonce == sealed.Nonce {
+		t.Errorf("records C1 and C2 share the nonce %s", sealed.Nonce)
+	}
+
+	aead, _ := collection_cipher(key)
+	nonce, _ := base64.StdEncoding.DecodeString(sealed.Nonce)
+	data, _ := base64.StdEncoding.DecodeString(sealed.Data)
+	plaintext, err := aead.Open(nil, nonce, data, []byte("C1|member"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opened map[string]string
+	if json.Unmarshal(plaintext, &opened) != nil || opened["memberId"] != "MEM1" || opened["subscriberId"] != "SUB1" {
+		t.Errorf("opened %s", plaintext)
+	}
+	if _, err := aead.Open(nil, nonce, data, []byte("C2|member")); err == nil {
+		t.Error("values sealed for C1 opened as C2")
+	}
+}
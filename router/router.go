@@ -0,0 +1,79 @@
+// Package router dispatches the Invoke and Query calls of the claimTransfer01 and consensus01 chaincodes to their
+// functions, checking the number of arguments each one takes.
+package router
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+var logger = shim.NewLogger("router")
+
+//==============================================================================================================================
+//	Route - A function the Invoke or Query router can call, with the number of arguments it takes. A MaxArgs of -1
+//			means there is no upper limit.
+//==============================================================================================================================
+type Route struct {
+	MinArgs int
+	MaxArgs int
+	Handler func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error)
+}
+
+//==============================================================================================================================
+//	 Exactly - Returns a Route for a function taking n arguments.
+//==============================================================================================================================
+func Exactly(n int, handler func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error)) Route {
+	return Route{MinArgs: n, MaxArgs: n, Handler: handler}
+}
+
+//==============================================================================================================================
+//	 Between - Returns a Route for a function taking from minArgs to maxArgs arguments.
+//==============================================================================================================================
+func Between(minArgs int, maxArgs int, handler func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error)) Route {
+	return Route{MinArgs: minArgs, MaxArgs: maxArgs, Handler: handler}
+}
+
+//==============================================================================================================================
+//	 expecting - Describes the number of arguments a Route takes for error messages.
+//==============================================================================================================================
+func (r Route) expecting() string {
+
+	if r.MaxArgs == r.MinArgs {
+		return strconv.Itoa(r.MinArgs)
+	}
+	if r.MaxArgs < 0 {
+		return "at least " + strconv.Itoa(r.MinArgs)
+	}
+	if r.MaxArgs == r.MinArgs+1 {
+		return strconv.Itoa(r.MinArgs) + " or " + strconv.Itoa(r.MaxArgs)
+	}
+	return "between " + strconv.Itoa(r.MinArgs) + " and " + strconv.Itoa(r.MaxArgs)
+}
+
+//==============================================================================================================================
+//	 Dispatch - Calls the function named from the routes passed after checking its number of arguments. Unknown
+//				functions and the wrong number of arguments are errors. A panic in the function is recovered and
+//				returned as an error so a bad request can not bring down the chaincode.
+//==============================================================================================================================
+func Dispatch(stub shim.ChaincodeStubInterface, kind string, routes map[string]Route, function string, args []string) (result []byte, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("%s %s panicked: %v", kind, function, r)
+			result = nil
+			err = fmt.Errorf("%s %s failed: %v", kind, function, r)
+		}
+	}()
+
+	route, ok := routes[function]
+	if !ok {
+		return nil, errors.New("Unknown " + kind + " function " + function)
+	}
+	if len(args) < route.MinArgs || (route.MaxArgs >= 0 && len(args) > route.MaxArgs) {
+		return nil, errors.New("Incorrect number of arguments for " + function + ". Expecting " + route.expecting())
+	}
+	return route.Handler(stub, args)
+}
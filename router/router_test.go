@@ -0,0 +1,55 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func echo(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	return []byte(strings.Join(args, ",")), nil
+}
+
+func TestDispatch(t *testing.T) {
+
+	routes := map[string]Route{
+		"none":     Exactly(0, echo),
+		"two":      Exactly(2, echo),
+		"oneOrTwo": Between(1, 2, echo),
+		"range":    Between(1, 3, echo),
+		"many":     Between(1, -1, echo),
+		"boom": Exactly(0, func(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+			var m map[string]int
+			m["x"] = 1
+			return nil, nil
+		}),
+	}
+
+	cases := []struct {
+		function string
+		args     []string
+		result   string
+		err      string
+	}{
+		{"none", nil, "", ""},
+		{"none", []string{"a"}, "", "Expecting 0"},
+		{"two", []string{"a", "b"}, "a,b", ""},
+		{"two", []string{"a"}, "", "Expecting 2"},
+		{"oneOrTwo", nil, "", "Expecting 1 or 2"},
+		{"range", []string{"a", "b", "c", "d"}, "", "Expecting between 1 and 3"},
+		{"many", []string{"a", "b", "c", "d"}, "a,b,c,d", ""},
+		{"many", nil, "", "Expecting at least 1"},
+		{"missing", nil, "", "Unknown Invoke function missing"},
+		{"boom", nil, "", "Invoke boom failed"},
+	}
+	for _, c := range cases {
+		result, err := Dispatch(nil, "Invoke", routes, c.function, c.args)
+		if c.err == "" && (err != nil || string(result) != c.result) {
+			t.Errorf("Dispatch(%s, %v) = %q, %v, expected %q", c.function, c.args, result, err, c.result)
+		}
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("Dispatch(%s, %v) error %v, expected %q", c.function, c.args, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package x12
+
+import (
+	"errors"
+
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	Claim - The claimTransfer01 claim record. The JSON tags match the ledger so a claim returned by get_claim_details
+//			can be unmarshalled directly. Fields the ledger sets during the workflow are only read when rendering an
+//			835.
+//==============================================================================================================================
+type Claim struct {
+	ClaimID        string        `json:"claimId"`
+	ServiceDate    string        `json:"serviceDate"`
+	AdmissionDate  string        `json:"admissionDate"`
+	ProviderID     string        `json:"providerId"`
+	MemberID       string        `json:"memberId"`
+	SubscriberID   string        `json:"subscriberId"`
+	DiagCode       string        `json:"diagCode"`
+	ProcedureCode  string        `json:"procedureCode"`
+	ProcedureDate  string        `json:"procedureDate"`
+	BillCode       string        `json:"billCode"`
+	SrvcUnitNbr    string        `json:"SrvcUnitNbr"`
+	RevenueCode    string        `json:"revenueCode"`
+	RevenueDesc    string        `json:"revenueDesc"`
+	AdmsnHourCode  string        `json:"admsnHourCode"`
+	AdmsnTypeCode  string        `json:"admsnTypeCode"`
+	AdmsnSrvcCode  string        `json:"admsnSrvcCode"`
+	UnitOfService  string        `json:"unitOfService"`
+	ChargedAmount  amount.Amount `json:"chargedAmount"`
+	NonCovAmount   amount.Amount `json:"nonCovAmount"`
+	ApprovedAmount amount.Amount `json:"approvedAmount"`
+	LocalPlanCode  string        `json:"localPlanCode"`
+	RemotePlanCode string        `json:"remotePlanCode"`
+	CostShare      amount.Amount `json:"costShare"`
+	FinalAmount    amount.Amount `json:"finalApprovedAmount"`
+	PaymentMethod  string        `json:"paymentMethod"`
+	ClaimStatus    string        `json:"claimStatus"`
+	PaymentRef     string        `json:"paymentReference"`
+	Lines          []ClaimLine   `json:"lines"`
+}
+
+//==============================================================================================================================
+//	ClaimLine - A service line of a claim, matching the chaincode's ClaimLine.
+//==============================================================================================================================
+type ClaimLine struct {
+	LineNumber       int           `json:"lineNumber"`
+	ProcedureCode    string        `json:"procedureCode"`
+	RevenueCode      string        `json:"revenueCode"`
+	BillCode         string        `json:"billCode"`
+	UnitOfService    string        `json:"unitOfService"`
+	ChargedAmount    amount.Amount `json:"chargedAmount"`
+	NonCovAmount     amount.Amount `json:"nonCovAmount"`
+	ApprovedAmount   amount.Amount `json:"approvedAmount"`
+	AdjustmentAmount amount.Amount `json:"adjustmentAmount"`
+	AdjustmentReason string        `json:"adjustmentReason"`
+}
+
+//==============================================================================================================================
+//	Payment - The settlement recorded on the ledger for a claim, as returned by get_payment.
+//==============================================================================================================================
+type Payment struct {
+	ReferenceNumber string        `json:"referenceNumber"`
+	ClaimID         string        `json:"claimId"`
+	PayerPlan       string        `json:"payerPlan"`
+	PayeePlan       string        `json:"payeePlan"`
+	Amount          amount.Amount `json:"amount"`
+	PaymentMethod   string        `json:"paymentMethod"`
+	SettlementDate  string        `json:"settlementDate"`
+}
+
+//==============================================================================================================================
+//	 Institutional - An institutional (837I) claim carries a UB-04 type of bill, a professional (837P) claim does not.
+//==============================================================================================================================
+func (c Claim) Institutional() bool {
+	return c.BillCode != ""
+}
+
+//==============================================================================================================================
+//	 InitArgs - Returns the twenty arguments the create_claim invoke takes to create the claim. The first service line
+//				is submitted with the claim, the rest are added with LineArgs.
+//==============================================================================================================================
+func (c Claim) InitArgs(owner string) ([]string, error) {
+
+	if len(c.Lines) == 0 {
+		return nil, errors.New("Claim " + c.ClaimID + " has no service lines")
+	}
+	first := c.Lines[0]
+	return []string{
+		c.ClaimID, c.ServiceDate, c.AdmissionDate, c.ProviderID, c.MemberID, c.SubscriberID, c.DiagCode,
+		first.ProcedureCode, c.ProcedureDate, c.BillCode, c.SrvcUnitNbr, first.RevenueCode, c.RevenueDesc,
+		c.AdmsnHourCode, c.AdmsnTypeCode, c.AdmsnSrvcCode, first.UnitOfService, first.ChargedAmount.String(),
+		first.NonCovAmount.String(), owner,
+	}, nil
+}
+
+//==============================================================================================================================
+//	 LineArgs - Returns the add_claim_line arguments for each service line after the first.
+//==============================================================================================================================
+func (c Claim) LineArgs(caller string) [][]string {
+
+	var args [][]string
+	for i := 1; i < len(c.Lines); i++ {
+		l := c.Lines[i]
+		args = append(args, []string{
+			caller, c.ClaimID, l.ProcedureCode, l.RevenueCode, l.BillCode, l.UnitOfService,
+			l.ChargedAmount.String(), l.NonCovAmount.String(),
+		})
+	}
+	return args
+}
+
+//==============================================================================================================================
+//	 finish_claim - Derives the claim level service fields from the lines the way the chaincode does and checks the
+//					total charge declared on the CLM segment.
+//==============================================================================================================================
+func finish_claim(c *Claim, declared amount.Amount) error {
+
+	if len(c.Lines) == 0 {
+		return errors.New("Claim " + c.ClaimID + " has no service lines")
+	}
+	c.ChargedAmount = 0
+	c.NonCovAmount = 0
+	for i := range c.Lines {
+		c.Lines[i].LineNumber = i + 1
+		c.Lines[i].BillCode = c.BillCode
+		c.ChargedAmount += c.Lines[i].ChargedAmount
+		c.NonCovAmount += c.Lines[i].NonCovAmount
+	}
+	if c.ChargedAmount != declared {
+		return errors.New("Claim " + c.ClaimID + " total charge " + declared.String() + " does not match the sum of its lines " + c.ChargedAmount.String())
+	}
+	first := c.Lines[0]
+	c.ProcedureCode = first.ProcedureCode
+	c.RevenueCode = first.RevenueCode
+	c.UnitOfService = first.UnitOfService
+	if c.MemberID == "" {
+		c.MemberID = c.SubscriberID // The subscriber is the patient
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package x12
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+//==============================================================================================================================
+//	 Implementation guides - The 5010 guide versions handled, as carried in GS08 and ST03.
+//==============================================================================================================================
+const VERSION_837P = "005010X222A1"
+const VERSION_837I = "005010X223A2"
+
+//==============================================================================================================================
+//	 Parse837 - Parses the claims in an 837P or 837I interchange. Each claim takes the billing provider NPI, the
+//				subscriber and the Home plan (the NM1*PR payer id) from the loops it is nested in. The ledger holds
+//				no separate patient id so a patient loop (HL 23) identifies the member with an MI qualified id on
+//				its NM1*QC segment, otherwise the subscriber is the member.
+//==============================================================================================================================
+func Parse837(data []byte) ([]Claim, error) {
+
+	segs, sep, err := split_interchange(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims []Claim
+	var c *Claim
+	var line *ClaimLine
+	var declared amount.Amount
+	var provider, subscriber, member, payer, version string
+	count := 0
+
+	finish := func() error {
+		if c == nil {
+			return nil
+		}
+		err := finish_claim(c, declared)
+		if err != nil {
+			return err
+		}
+		claims = append(claims, *c)
+		c, line = nil, nil
+		return nil
+	}
+
+	for _, s := range segs {
+		if count > 0 {
+			count++
+		}
+		switch s[0] {
+		case "ST":
+			if s.el(1) != "837" {
+				return nil, errors.New("Transaction set " + s.el(1) + " is not an 837")
+			}
+			version = s.el(3)
+			if version != VERSION_837P && version != VERSION_837I {
+				return nil, errors.New("Unsupported 837 version " + version)
+			}
+			count = 1
+		case "SE":
+			err = finish()
+			if err != nil {
+				return nil, err
+			}
+			err = check_transaction_set(s, count)
+			if err != nil {
+				return nil, err
+			}
+			count = 0
+		case "HL":
+			err = finish()
+			if err != nil {
+				return nil, err
+			}
+			switch s.el(3) {
+			case "20": // Billing provider
+				provider, subscriber, member, payer = "", "", "", ""
+			case "22": // Subscriber
+				subscriber, member, payer = "", "", ""
+			case "23": // Patient
+				member = ""
+			}
+		case "NM1":
+			switch s.el(1) {
+			case "85":
+				provider = s.el(9)
+			case "IL":
+				subscriber = s.el(9)
+			case "QC":
+				if s.el(8) == "MI" {
+					member = s.el(9)
+				}
+			case "PR":
+				payer = s.el(9)
+			}
+		case "CLM":
+			err = finish()
+			if err != nil {
+				return nil, err
+			}
+			declared, err = amount.Parse(s.el(2))
+			if err != nil {
+				return nil, errors.New("Claim " + s.el(1) + ": " + err.Error())
+			}
+			c = &Claim{ClaimID: s.el(1), ProviderID: provider, SubscriberID: subscriber, MemberID: member, RemotePlanCode: payer}
+			if version == VERSION_837I {
+				facility := strings.Split(s.el(5), sep.component)
+				if len(facility) < 3 {
+					return nil, errors.New("Claim " + c.ClaimID + " has no type of bill")
+				}
+				c.BillCode = facility[0] + facility[2] // Facility type code and claim frequency
+			}
+		case "DTP", "CL1", "HI", "LX", "SV1", "SV2":
+			if c == nil {
+				continue // Not part of a claim
+			}
+			err = parse_claim_segment(s, sep, c, &line)
+			if err != nil {
+				return nil, errors.New("Claim " + c.ClaimID + ": " + err.Error())
+			}
+		}
+	}
+	if c != nil || count != 0 {
+		return nil, errors.New("Transaction set is not terminated by an SE segment")
+	}
+	if len(claims) == 0 {
+		return nil, errors.New("Interchange holds no claims")
+	}
+	return claims, nil
+}
+
+//==============================================================================================================================
+//	 parse_claim_segment - Applies a segment from inside a claim loop to the claim or its current service line.
+//==============================================================================================================================
+func parse_claim_segment(s segment, sep separators, c *Claim, line **ClaimLine) error {
+
+	switch s[0] {
+	case "LX":
+		c.Lines = append(c.Lines, ClaimLine{})
+		*line = &c.Lines[len(c.Lines)-1]
+		return nil
+	case "SV1", "SV2":
+		if *line == nil {
+			return errors.New(s[0] + " segment outside a service line")
+		}
+		return parse_service_line(s, sep, *line)
+	case "DTP":
+		value := s.el(3)
+		switch s.el(1) {
+		case "472": // Service date, a range is held as its first date
+			d, err := ledger_date(strings.Split(value, "-")[0])
+			if err != nil {
+				return err
+			}
+			if *line != nil && *line == &c.Lines[0] {
+				c.ServiceDate = d
+				c.ProcedureDate = d
+			}
+		case "434": // Statement period, used when the lines carry no dates
+			d, err := ledger_date(strings.Split(value, "-")[0])
+			if err != nil {
+				return err
+			}
+			if c.ServiceDate == "" {
+				c.ServiceDate = d
+			}
+		case "435": // Admission date and hour
+			if len(value) < 8 {
+				return errors.New("Invalid admission date " + value)
+			}
+			d, err := ledger_date(value[:8])
+			if err != nil {
+				return err
+			}
+			c.AdmissionDate = d
+			if s.el(2) == "DT" && len(value) >= 10 {
+				c.AdmsnHourCode = value[8:10]
+			}
+		}
+	case "CL1":
+		c.AdmsnTypeCode = s.el(1)
+		c.AdmsnSrvcCode = s.el(2)
+	case "HI":
+		code := strings.Split(s.el(1), sep.component)
+		if c.DiagCode == "" && len(code) > 1 && (code[0] == "ABK" || code[0] == "BK") {
+			c.DiagCode = code[1] // Principal diagnosis
+		}
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 parse_service_line - Reads a professional (SV1) or institutional (SV2) service line.
+//==============================================================================================================================
+func parse_service_line(s segment, sep separators, l *ClaimLine) error {
+
+	procedure, charged, units, nonCov := s.el(1), s.el(2), s.el(4), ""
+	if s[0] == "SV2" {
+		l.RevenueCode = s.el(1)
+		procedure, charged, units, nonCov = s.el(2), s.el(3), s.el(5), s.el(7)
+	}
+	if procedure != "" {
+		code := strings.Split(procedure, sep.component)
+		if len(code) < 2 || code[0] != "HC" {
+			return errors.New("Unsupported procedure code " + procedure + ", expecting HC qualified CPT or HCPCS")
+		}
+		l.ProcedureCode = code[1]
+	}
+	var err error
+	l.ChargedAmount, err = amount.Parse(charged)
+	if err != nil {
+		return err
+	}
+	l.NonCovAmount, err = amount.Parse(nonCov)
+	if err != nil {
+		return err
+	}
+	l.UnitOfService = units
+	return nil
+}
+
+//==============================================================================================================================
+//	 Render837 - Renders claims as an 837 interchange, an 837I when the claims are institutional and an 837P when they
+//				 are professional. Names are not held on the ledger so parties are named by their ids.
+//==============================================================================================================================
+func Render837(env Envelope, claims []Claim) ([]byte, error) {
+
+	if len(claims) == 0 {
+		return nil, errors.New("No claims to render")
+	}
+	institutional := claims[0].Institutional()
+	version := VERSION_837P
+	if institutional {
+		version = VERSION_837I
+	}
+	for _, c := range claims {
+		if c.Institutional() != institutional {
+			return nil, errors.New("An 837 holds either professional or institutional claims, not both")
+		}
+	}
+
+	return render_interchange(env, "HC", "837", version, func(w *writer) error {
+		w.add("BHT", "0019", "00", strconv.Itoa(env.ControlNumber), env.Created.Format("20060102"), env.Created.Format("1504"), "CH")
+		w.add("NM1", "41", "2", env.SenderID, "", "", "", "", "46", env.SenderID)
+		w.add("NM1", "40", "2", env.ReceiverID, "", "", "", "", "46", env.ReceiverID)
+		hl := 0
+		for _, c := range claims {
+			err := render_claim(w, c, &hl)
+			if err != nil {
+				return errors.New("Claim " + c.ClaimID + ": " + err.Error())
+			}
+		}
+		return nil
+	})
+}
+
+//==============================================================================================================================
+//	 render_claim - Adds the billing provider, subscriber, optional patient and claim loops for one claim.
+//==============================================================================================================================
+func render_claim(w *writer, c Claim, hl *int) error {
+
+	if len(c.Lines) == 0 {
+		return errors.New("No service lines")
+	}
+
+	*hl++
+	provider := strconv.Itoa(*hl)
+	w.add("HL", provider, "", "20", "1")
+	w.add("NM1", "85", "2", c.ProviderID, "", "", "", "", "XX", c.ProviderID)
+
+	*hl++
+	subscriber := strconv.Itoa(*hl)
+	patient := c.MemberID != "" && c.MemberID != c.SubscriberID
+	if patient {
+		w.add("HL", subscriber, provider, "22", "1")
+		w.add("SBR", "P", "", "", "", "", "", "", "", "BL")
+	} else {
+		w.add("HL", subscriber, provider, "22", "0")
+		w.add("SBR", "P", "18", "", "", "", "", "", "", "BL")
+	}
+	w.add("NM1", "IL", "1", c.SubscriberID, "", "", "", "", "MI", c.SubscriberID)
+	if c.RemotePlanCode != "" && c.RemotePlanCode != "UNDEFINED" {
+		w.add("NM1", "PR", "2", c.RemotePlanCode, "", "", "", "", "PI", c.RemotePlanCode)
+	}
+	if patient {
+		*hl++
+		w.add("HL", strconv.Itoa(*hl), subscriber, "23", "0")
+		w.add("PAT", "G8")
+		w.add("NM1", "QC", "1", c.MemberID, "", "", "", "", "MI", c.MemberID)
+	}
+
+	var charged amount.Amount
+	for _, l := range c.Lines {
+		charged += l.ChargedAmount
+	}
+	serviceDate, err := x12_date(c.ServiceDate)
+	if err != nil {
+		return err
+	}
+	lineDate := serviceDate
+	if c.ProcedureDate != "" {
+		lineDate, err = x12_date(c.ProcedureDate)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Institutional() {
+		bill := strings.TrimPrefix(c.BillCode, "0")
+		if len(bill) != 3 {
+			return errors.New("Invalid type of bill " + c.BillCode)
+		}
+		w.add("CLM", c.ClaimID, charged.String(), "", "", w.composite(bill[:2], "A", bill[2:]), "", "A", "Y", "Y")
+		w.add("DTP", "434", "RD8", serviceDate+"-"+serviceDate)
+		if c.AdmissionDate != "" {
+			admitted, err := x12_date(c.AdmissionDate)
+			if err != nil {
+				return err
+			}
+			if c.AdmsnHourCode != "" {
+				w.add("DTP", "435", "DT", admitted+c.AdmsnHourCode+"00")
+			} else {
+				w.add("DTP", "435", "D8", admitted)
+			}
+		}
+		if c.AdmsnTypeCode != "" || c.AdmsnSrvcCode != "" {
+			w.add("CL1", c.AdmsnTypeCode, c.AdmsnSrvcCode, "01")
+		}
+	} else {
+		w.add("CLM", c.ClaimID, charged.String(), "", "", w.composite("11", "B", "1"), "Y", "A", "Y", "Y")
+	}
+	w.add("HI", w.composite("ABK", strings.ToUpper(strings.Replace(c.DiagCode, ".", "", -1))))
+
+	for i, l := range c.Lines {
+		w.add("LX", strconv.Itoa(i+1))
+		procedure := ""
+		if l.ProcedureCode != "" {
+			procedure = w.composite("HC", l.ProcedureCode)
+		}
+		if c.Institutional() {
+			if l.RevenueCode == "" {
+				return errors.New("Institutional line " + strconv.Itoa(i+1) + " has no revenue code")
+			}
+			nonCov := ""
+			if l.NonCovAmount != 0 {
+				nonCov = l.NonCovAmount.String()
+			}
+			w.add("SV2", l.RevenueCode, procedure, l.ChargedAmount.String(), "UN", l.UnitOfService, "", nonCov)
+		} else {
+			w.add("SV1", procedure, l.ChargedAmount.String(), "UN", l.UnitOfService, "", "", "1")
+		}
+		w.add("DTP", "472", "D8", lineDate)
+	}
+	return nil
+}
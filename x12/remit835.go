@@ -0,0 +1,354 @@
+package x12
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+const VERSION_835 = "005010X221A1"
+
+const STATUS_PAYMENT_COMPLETE = "PAYMENTCOMPLETE"
+
+//==============================================================================================================================
+//	 Payment methods - Ledger payment methods and the BPR04 payment method codes they are sent as. Codes are read back
+//					   as the first ledger method listed for them.
+//==============================================================================================================================
+var paymentMethodCodes = map[string]string{
+	"EFT":   "ACH",
+	"ACH":   "ACH",
+	"CHECK": "CHK",
+	"CHK":   "CHK",
+}
+
+var ledgerPaymentMethods = map[string]string{
+	"ACH": "EFT",
+	"CHK": "CHECK",
+}
+
+//==============================================================================================================================
+//	Adjustment - A claim adjustment (CAS) explaining part of the difference between the charged and paid amounts.
+//==============================================================================================================================
+type Adjustment struct {
+	Group  string        `json:"group"`
+	Reason string        `json:"reason"`
+	Amount amount.Amount `json:"amount"`
+}
+
+//==============================================================================================================================
+//	RemittanceLine - The payment of one service line.
+//==============================================================================================================================
+type RemittanceLine struct {
+	ProcedureCode string        `json:"procedureCode"`
+	RevenueCode   string        `json:"revenueCode"`
+	UnitOfService string        `json:"unitOfService"`
+	ChargedAmount amount.Amount `json:"chargedAmount"`
+	PaidAmount    amount.Amount `json:"paidAmount"`
+	Adjustments   []Adjustment  `json:"adjustments"`
+}
+
+//==============================================================================================================================
+//	RemittanceClaim - The payment of one claim. PatientResp is the member's cost share.
+//==============================================================================================================================
+type RemittanceClaim struct {
+	ClaimID       string           `json:"claimId"`
+	MemberID      string           `json:"memberId"`
+	ServiceDate   string           `json:"serviceDate"`
+	ChargedAmount amount.Amount    `json:"chargedAmount"`
+	PaidAmount    amount.Amount    `json:"paidAmount"`
+	PatientResp   amount.Amount    `json:"patientResp"`
+	Adjustments   []Adjustment     `json:"adjustments"`
+	Lines         []RemittanceLine `json:"lines"`
+}
+
+//==============================================================================================================================
+//	Remittance - The settlement data of one 835 transaction set. The Home plan that settled the claim is the payer
+//				 and the billing provider the payee.
+//==============================================================================================================================
+type Remittance struct {
+	ReferenceNumber string            `json:"referenceNumber"`
+	PaymentMethod   string            `json:"paymentMethod"`
+	PaymentAmount   amount.Amount     `json:"paymentAmount"`
+	SettlementDate  string            `json:"settlementDate"`
+	PayerPlan       string            `json:"payerPlan"`
+	PayeeID         string            `json:"payeeId"`
+	Claims          []RemittanceClaim `json:"claims"`
+}
+
+//==============================================================================================================================
+//	 split_reason - Splits a ledger adjustment reason such as "CO45" into its CAS group and reason codes. Lines with no
+//					usable reason are reported as a contractual obligation (CO 45).
+//==============================================================================================================================
+func split_reason(reason string) (string, string) {
+
+	reason = strings.ToUpper(strings.TrimSpace(reason))
+	if len(reason) < 3 {
+		return "CO", "45"
+	}
+	switch reason[:2] {
+	case "CO", "CR", "OA", "PI", "PR":
+		return reason[:2], reason[2:]
+	}
+	return "CO", "45"
+}
+
+//==============================================================================================================================
+//	 NewRemittance - Builds the remittance for a claim whose payment is complete from the claim and the Payment the
+//					 ledger recorded for it.
+//==============================================================================================================================
+func NewRemittance(c Claim, p Payment) (Remittance, error) {
+
+	var r Remittance
+
+	if c.ClaimStatus != STATUS_PAYMENT_COMPLETE {
+		return r, errors.New("Claim " + c.ClaimID + " is " + c.ClaimStatus + ", a remittance needs " + STATUS_PAYMENT_COMPLETE)
+	}
+	if p.ClaimID != c.ClaimID {
+		return r, errors.New("Payment " + p.ReferenceNumber + " is not for claim " + c.ClaimID)
+	}
+	if p.Amount != c.FinalAmount {
+		return r, errors.New("Payment " + p.ReferenceNumber + " of " + p.Amount.String() + " does not match the final amount " + c.FinalAmount.String())
+	}
+	code, ok := paymentMethodCodes[strings.ToUpper(p.PaymentMethod)]
+	if !ok {
+		return r, errors.New("Unsupported payment method " + p.PaymentMethod)
+	}
+
+	rc := RemittanceClaim{
+		ClaimID:       c.ClaimID,
+		MemberID:      c.MemberID,
+		ServiceDate:   c.ServiceDate,
+		ChargedAmount: c.ChargedAmount,
+		PaidAmount:    c.FinalAmount,
+		PatientResp:   c.CostShare,
+	}
+	if c.CostShare != 0 {
+		rc.Adjustments = append(rc.Adjustments, Adjustment{Group: "PR", Reason: "2", Amount: c.CostShare})
+	}
+	for _, l := range c.Lines {
+		rl := RemittanceLine{
+			ProcedureCode: l.ProcedureCode,
+			RevenueCode:   l.RevenueCode,
+			UnitOfService: l.UnitOfService,
+			ChargedAmount: l.ChargedAmount,
+			PaidAmount:    l.ApprovedAmount,
+		}
+		if l.ChargedAmount != l.ApprovedAmount {
+			group, reason := split_reason(l.AdjustmentReason)
+			rl.Adjustments = append(rl.Adjustments, Adjustment{Group: group, Reason: reason, Amount: l.ChargedAmount - l.ApprovedAmount})
+		}
+		rc.Lines = append(rc.Lines, rl)
+	}
+
+	r = Remittance{
+		ReferenceNumber: p.ReferenceNumber,
+		PaymentMethod:   ledgerPaymentMethods[code],
+		PaymentAmount:   p.Amount,
+		SettlementDate:  p.SettlementDate,
+		PayerPlan:       p.PayerPlan,
+		PayeeID:         c.ProviderID,
+		Claims:          []RemittanceClaim{rc},
+	}
+	return r, nil
+}
+
+//==============================================================================================================================
+//	 Render835 - Renders the settlement of a claim whose payment is complete as an 835 interchange.
+//==============================================================================================================================
+func Render835(env Envelope, c Claim, p Payment) ([]byte, error) {
+
+	r, err := NewRemittance(c, p)
+	if err != nil {
+		return nil, err
+	}
+	settled, err := x12_date(r.SettlementDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return render_interchange(env, "HP", "835", VERSION_835, func(w *writer) error {
+		bpr := make([]string, 17)
+		bpr[0], bpr[1], bpr[2], bpr[3], bpr[4], bpr[16] = "BPR", "I", r.PaymentAmount.String(), "C", paymentMethodCodes[r.PaymentMethod], settled
+		w.add(bpr...)
+		w.add("TRN", "1", r.ReferenceNumber, r.PayerPlan)
+		w.add("DTM", "405", settled)
+		w.add("N1", "PR", r.PayerPlan)
+		w.add("N1", "PE", r.PayeeID, "XX", r.PayeeID)
+
+		for i, rc := range r.Claims {
+			w.add("LX", strconv.Itoa(i+1))
+			w.add("CLP", rc.ClaimID, "1", rc.ChargedAmount.String(), rc.PaidAmount.String(), rc.PatientResp.String(), "BL", rc.ClaimID)
+			for _, a := range rc.Adjustments {
+				w.add("CAS", a.Group, a.Reason, a.Amount.String())
+			}
+			w.add("NM1", "QC", "1", rc.MemberID, "", "", "", "", "MI", rc.MemberID)
+			if rc.ServiceDate != "" {
+				served, err := x12_date(rc.ServiceDate)
+				if err != nil {
+					return err
+				}
+				w.add("DTM", "232", served)
+			}
+			for _, l := range rc.Lines {
+				procedure := w.composite("HC", l.ProcedureCode)
+				if l.ProcedureCode == "" {
+					procedure = w.composite("NU", l.RevenueCode)
+				}
+				w.add("SVC", procedure, l.ChargedAmount.String(), l.PaidAmount.String(), l.RevenueCode, l.UnitOfService)
+				for _, a := range l.Adjustments {
+					w.add("CAS", a.Group, a.Reason, a.Amount.String())
+				}
+			}
+		}
+		return nil
+	})
+}
+
+//==============================================================================================================================
+//	 Parse835 - Parses the remittances in an 835 interchange, one for each transaction set.
+//==============================================================================================================================
+func Parse835(data []byte) ([]Remittance, error) {
+
+	segs, sep, err := split_interchange(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var remittances []Remittance
+	var r *Remittance
+	var rc *RemittanceClaim
+	var line *RemittanceLine
+	count := 0
+
+	for _, s := range segs {
+		if count > 0 {
+			count++
+		}
+		if r == nil && s[0] != "ST" {
+			continue
+		}
+		switch s[0] {
+		case "ST":
+			if s.el(1) != "835" {
+				return nil, errors.New("Transaction set " + s.el(1) + " is not an 835")
+			}
+			r = &Remittance{}
+			rc, line = nil, nil
+			count = 1
+		case "SE":
+			err = check_transaction_set(s, count)
+			if err != nil {
+				return nil, err
+			}
+			remittances = append(remittances, *r)
+			r = nil
+			count = 0
+		case "BPR":
+			r.PaymentAmount, err = amount.Parse(s.el(2))
+			if err != nil {
+				return nil, err
+			}
+			r.PaymentMethod = s.el(4)
+			if method, ok := ledgerPaymentMethods[s.el(4)]; ok {
+				r.PaymentMethod = method
+			}
+			if s.el(16) != "" {
+				r.SettlementDate, err = ledger_date(s.el(16))
+				if err != nil {
+					return nil, err
+				}
+			}
+		case "TRN":
+			r.ReferenceNumber = s.el(2)
+		case "N1":
+			switch s.el(1) {
+			case "PR":
+				r.PayerPlan = s.el(2)
+			case "PE":
+				r.PayeeID = s.el(2)
+				if s.el(3) == "XX" {
+					r.PayeeID = s.el(4)
+				}
+			}
+		case "CLP":
+			r.Claims = append(r.Claims, RemittanceClaim{ClaimID: s.el(1)})
+			rc, line = &r.Claims[len(r.Claims)-1], nil
+			amounts := []*amount.Amount{&rc.ChargedAmount, &rc.PaidAmount, &rc.PatientResp}
+			for i, a := range amounts {
+				*a, err = amount.Parse(s.el(i + 3))
+				if err != nil {
+					return nil, errors.New("Claim " + rc.ClaimID + ": " + err.Error())
+				}
+			}
+		case "CAS", "NM1", "DTM", "SVC":
+			if rc == nil {
+				continue // Not part of a claim
+			}
+			line, err = parse_claim_payment(s, sep, rc, line)
+			if err != nil {
+				return nil, errors.New("Claim " + rc.ClaimID + ": " + err.Error())
+			}
+		}
+	}
+	if r != nil {
+		return nil, errors.New("Transaction set is not terminated by an SE segment")
+	}
+	if len(remittances) == 0 {
+		return nil, errors.New("Interchange holds no remittances")
+	}
+	return remittances, nil
+}
+
+//==============================================================================================================================
+//	 parse_claim_payment - Applies a segment from inside a claim payment loop to the claim or its current service line,
+//						   returning the current service line.
+//==============================================================================================================================
+func parse_claim_payment(s segment, sep separators, rc *RemittanceClaim, line *RemittanceLine) (*RemittanceLine, error) {
+
+	switch s[0] {
+	case "CAS":
+		for i := 2; i+1 < len(s) && s[i] != ""; i += 3 { // Reason and amount repeat in threes with a quantity
+			value, err := amount.Parse(s.el(i + 1))
+			if err != nil {
+				return line, err
+			}
+			a := Adjustment{Group: s.el(1), Reason: s[i], Amount: value}
+			if line != nil {
+				line.Adjustments = append(line.Adjustments, a)
+			} else {
+				rc.Adjustments = append(rc.Adjustments, a)
+			}
+		}
+	case "NM1":
+		if s.el(1) == "QC" {
+			rc.MemberID = s.el(9)
+		}
+	case "DTM":
+		if s.el(1) == "232" {
+			d, err := ledger_date(s.el(2))
+			if err != nil {
+				return line, err
+			}
+			rc.ServiceDate = d
+		}
+	case "SVC":
+		rc.Lines = append(rc.Lines, RemittanceLine{RevenueCode: s.el(4), UnitOfService: s.el(5)})
+		line = &rc.Lines[len(rc.Lines)-1]
+		code := strings.Split(s.el(1), sep.component)
+		if len(code) > 1 && code[0] == "HC" {
+			line.ProcedureCode = code[1]
+		}
+		var err error
+		line.ChargedAmount, err = amount.Parse(s.el(2))
+		if err != nil {
+			return line, err
+		}
+		line.PaidAmount, err = amount.Parse(s.el(3))
+		if err != nil {
+			return line, err
+		}
+	}
+	return line, nil
+}
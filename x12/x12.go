@@ -0,0 +1,197 @@
+// Package x12 converts between ASC X12 5010 health care transactions and the claim records held by the
+// claimTransfer01 chaincode. 837P and 837I claims are parsed into Claims ready for submission and a claim whose
+// payment is complete is rendered as an 835 remittance.
+package x12
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//==============================================================================================================================
+//	Envelope - The interchange (ISA/IEA) and functional group (GS/GE) details used when rendering a transaction. The
+//			   ControlNumber is used for the interchange, group and BHT/TRN references.
+//==============================================================================================================================
+type Envelope struct {
+	SenderID      string
+	ReceiverID    string
+	ControlNumber int
+	Created       time.Time
+	Production    bool
+}
+
+//==============================================================================================================================
+//	 Separators - Transactions are rendered with the common delimiters, a parsed interchange uses whatever its ISA
+//				  segment declares.
+//==============================================================================================================================
+type separators struct {
+	element   string
+	component string
+	segment   string
+}
+
+var defaultSeparators = separators{element: "*", component: ":", segment: "~"}
+
+//==============================================================================================================================
+//	segment - One X12 segment split into its elements. Element 0 is the segment id.
+//==============================================================================================================================
+type segment []string
+
+//==============================================================================================================================
+//	 el - Returns element i of the segment, or an empty string when the segment is shorter.
+//==============================================================================================================================
+func (s segment) el(i int) string {
+
+	if i < len(s) {
+		return s[i]
+	}
+	return ""
+}
+
+//==============================================================================================================================
+//	 split_interchange - Splits an interchange into segments using the delimiters declared by its fixed length ISA
+//						 segment. Line breaks between segments are ignored.
+//==============================================================================================================================
+func split_interchange(data []byte) ([]segment, separators, error) {
+
+	var sep separators
+
+	text := strings.TrimLeft(string(data), " \t\r\n\ufeff")
+	if len(text) < 106 || !strings.HasPrefix(text, "ISA") {
+		return nil, sep, errors.New("Interchange does not start with an ISA segment")
+	}
+	sep = separators{element: text[3:4], component: text[104:105], segment: text[105:106]}
+
+	var segs []segment
+	for _, raw := range strings.Split(text, sep.segment) {
+		raw = strings.Trim(raw, "\r\n")
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		segs = append(segs, segment(strings.Split(raw, sep.element)))
+	}
+	if segs[len(segs)-1][0] != "IEA" {
+		return nil, sep, errors.New("Interchange is not terminated by an IEA segment")
+	}
+	return segs, sep, nil
+}
+
+//==============================================================================================================================
+//	writer - Builds the segments of one interchange holding a single transaction set.
+//==============================================================================================================================
+type writer struct {
+	sep      separators
+	lines    []string
+	stCount  int
+	inTxnSet bool
+}
+
+//==============================================================================================================================
+//	 add - Appends a segment, dropping trailing empty elements as X12 requires.
+//==============================================================================================================================
+func (w *writer) add(elements ...string) {
+
+	for len(elements) > 1 && elements[len(elements)-1] == "" {
+		elements = elements[:len(elements)-1]
+	}
+	w.lines = append(w.lines, strings.Join(elements, w.sep.element)+w.sep.segment)
+	if w.inTxnSet {
+		w.stCount++
+	}
+}
+
+//==============================================================================================================================
+//	 composite - Joins the components of a composite element.
+//==============================================================================================================================
+func (w *writer) composite(components ...string) string {
+
+	for len(components) > 1 && components[len(components)-1] == "" {
+		components = components[:len(components)-1]
+	}
+	return strings.Join(components, w.sep.component)
+}
+
+//==============================================================================================================================
+//	 render_interchange - Wraps one transaction set in its ST/SE, GS/GE and ISA/IEA envelopes. body adds the segments
+//						  between the ST and SE.
+//==============================================================================================================================
+func render_interchange(env Envelope, functionalID string, txnSet string, version string, body func(w *writer) error) ([]byte, error) {
+
+	if env.SenderID == "" || env.ReceiverID == "" {
+		return nil, errors.New("Sender and receiver ids are required")
+	}
+	if len(env.SenderID) > 15 || len(env.ReceiverID) > 15 {
+		return nil, errors.New("Sender and receiver ids are limited to 15 characters")
+	}
+	if env.ControlNumber <= 0 || env.ControlNumber > 999999999 {
+		return nil, fmt.Errorf("Invalid control number %d", env.ControlNumber)
+	}
+	usage := "T"
+	if env.Production {
+		usage = "P"
+	}
+	control := strconv.Itoa(env.ControlNumber)
+
+	w := &writer{sep: defaultSeparators}
+	w.add("ISA", "00", strings.Repeat(" ", 10), "00", strings.Repeat(" ", 10), "ZZ", fmt.Sprintf("%-15s", env.SenderID),
+		"ZZ", fmt.Sprintf("%-15s", env.ReceiverID), env.Created.Format("060102"), env.Created.Format("1504"), "^", "00501",
+		fmt.Sprintf("%09d", env.ControlNumber), "0", usage, w.sep.component)
+	w.add("GS", functionalID, env.SenderID, env.ReceiverID, env.Created.Format("20060102"), env.Created.Format("1504"), control, "X", version)
+
+	w.inTxnSet = true
+	w.add("ST", txnSet, "0001", version)
+	err := body(w)
+	if err != nil {
+		return nil, err
+	}
+	w.add("SE", strconv.Itoa(w.stCount+1), "0001")
+	w.inTxnSet = false
+
+	w.add("GE", "1", control)
+	w.add("IEA", "1", fmt.Sprintf("%09d", env.ControlNumber))
+
+	return []byte(strings.Join(w.lines, "\n") + "\n"), nil
+}
+
+//==============================================================================================================================
+//	 check_transaction_set - Checks an SE segment's count of the segments from the ST to the SE inclusive.
+//==============================================================================================================================
+func check_transaction_set(se segment, count int) error {
+
+	n, err := strconv.Atoi(se.el(1))
+	if err != nil || n != count {
+		return fmt.Errorf("Transaction set %s has %d segments but SE declares %s", se.el(2), count, se.el(1))
+	}
+	return nil
+}
+
+//==============================================================================================================================
+//	 x12_date - Converts a YYYY-MM-DD date to the CCYYMMDD format used in X12. Dates already in CCYYMMDD are returned
+//				unchanged.
+//==============================================================================================================================
+func x12_date(value string) (string, error) {
+
+	d, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		d, err = time.Parse("20060102", value)
+	}
+	if err != nil {
+		return "", errors.New("Invalid date " + value + ", expecting YYYY-MM-DD")
+	}
+	return d.Format("20060102"), nil
+}
+
+//==============================================================================================================================
+//	 ledger_date - Converts an X12 CCYYMMDD date to the YYYY-MM-DD format held on the ledger.
+//==============================================================================================================================
+func ledger_date(value string) (string, error) {
+
+	d, err := time.Parse("20060102", value)
+	if err != nil {
+		return "", errors.New("Invalid X12 date " + value + ", expecting CCYYMMDD")
+	}
+	return d.Format("2006-01-02"), nil
+}
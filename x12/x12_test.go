@@ -0,0 +1,231 @@
+package x12
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibm-blockchain/example02/amount"
+)
+
+var testEnvelope = Envelope{
+	SenderID:      "SUBMITTER",
+	ReceiverID:    "RECEIVER",
+	ControlNumber: 201,
+	Created:       time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC),
+}
+
+func read_sample(t *testing.T, name string) []byte {
+
+	data, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func read_json(t *testing.T, name string, v interface{}) {
+
+	err := json.Unmarshal(read_sample(t, name), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParse837P(t *testing.T) {
+
+	claims, err := Parse837(read_sample(t, "837p_sample.x12"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) != 2 {
+		t.Fatalf("expected 2 claims, got %d", len(claims))
+	}
+
+	c := claims[0]
+	if c.ClaimID != "P100" || c.ProviderID != "1234567893" || c.MemberID != "SUB1" || c.SubscriberID != "SUB1" || c.RemotePlanCode != "R" {
+		t.Errorf("claim parties not parsed: %+v", c)
+	}
+	if c.Institutional() || c.DiagCode != "A011" || c.ServiceDate != "2024-01-02" || c.ProcedureDate != "2024-01-02" {
+		t.Errorf("claim details not parsed: %+v", c)
+	}
+	if c.ChargedAmount.String() != "150.50" || len(c.Lines) != 2 {
+		t.Fatalf("claim lines not parsed: %+v", c)
+	}
+	if l := c.Lines[1]; l.LineNumber != 2 || l.ProcedureCode != "99214" || l.UnitOfService != "2" || l.ChargedAmount.String() != "50.50" {
+		t.Errorf("line 2 not parsed: %+v", l)
+	}
+
+	c = claims[1]
+	if c.ClaimID != "P101" || c.MemberID != "MEM2" || c.SubscriberID != "SUB1" || c.DiagCode != "J020" {
+		t.Errorf("patient claim not parsed: %+v", c)
+	}
+}
+
+func TestParse837I(t *testing.T) {
+
+	claims, err := Parse837(read_sample(t, "837i_sample.x12"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claims) != 1 {
+		t.Fatalf("expected 1 claim, got %d", len(claims))
+	}
+
+	c := claims[0]
+	if !c.Institutional() || c.BillCode != "111" || c.AdmissionDate != "2024-01-01" || c.AdmsnHourCode != "10" {
+		t.Errorf("institutional details not parsed: %+v", c)
+	}
+	if c.AdmsnTypeCode != "1" || c.AdmsnSrvcCode != "7" || c.RevenueCode != "0450" || c.NonCovAmount.String() != "10.00" {
+		t.Errorf("institutional codes not parsed: %+v", c)
+	}
+	if l := c.Lines[1]; l.RevenueCode != "0451" || l.BillCode != "111" || l.NonCovAmount != 0 {
+		t.Errorf("line 2 not parsed: %+v", l)
+	}
+}
+
+func TestRoundTrip837(t *testing.T) {
+
+	for _, name := range []string{"837p_sample.x12", "837i_sample.x12"} {
+		claims, err := Parse837(read_sample(t, name))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		rendered, err := Render837(testEnvelope, claims)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		again, err := Parse837(rendered)
+		if err != nil {
+			t.Fatalf("%s: rendered 837 does not parse: %v\n%s", name, err, rendered)
+		}
+		if !reflect.DeepEqual(claims, again) {
+			t.Errorf("%s: round trip changed the claims\n%+v\n%+v", name, claims, again)
+		}
+	}
+}
+
+func TestRender837Mixed(t *testing.T) {
+
+	professional, err := Parse837(read_sample(t, "837p_sample.x12"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	institutional, err := Parse837(read_sample(t, "837i_sample.x12"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Render837(testEnvelope, append(professional, institutional...))
+	if err == nil {
+		t.Error("expected professional and institutional claims to be rejected together")
+	}
+}
+
+func TestParse837Errors(t *testing.T) {
+
+	sample := string(read_sample(t, "837p_sample.x12"))
+	cases := map[string]string{
+		"total":   strings.Replace(sample, "CLM*P100*150.5*", "CLM*P100*150.6*", 1),
+		"count":   strings.Replace(sample, "SE*", "SE*9", 1),
+		"missing": strings.Replace(sample, "ISA*", "XXX*", 1),
+	}
+	for name, data := range cases {
+		if _, err := Parse837([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSubmissionArgs(t *testing.T) {
+
+	claims, err := Parse837(read_sample(t, "837i_sample.x12"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := claims[0].InitArgs("user_type1_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"I200", "2024-01-02", "2024-01-01", "1234567893", "SUB1", "SUB1", "A011", "99213", "2024-01-02",
+		"111", "", "0450", "", "10", "1", "7", "1", "100.00", "10.00", "user_type1_0"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("unexpected create_claim args %q", args)
+	}
+	lines := claims[0].LineArgs("user_type1_0")
+	if len(lines) != 1 || !reflect.DeepEqual(lines[0], []string{"user_type1_0", "I200", "99214", "0451", "111", "2", "50.50", "0.00"}) {
+		t.Errorf("unexpected add_claim_line args %q", lines)
+	}
+}
+
+func TestRender835(t *testing.T) {
+
+	var c Claim
+	var p Payment
+	read_json(t, "claim_paid.json", &c)
+	read_json(t, "payment_paid.json", &p)
+
+	rendered, err := Render835(testEnvelope, c, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := read_sample(t, "835_paid.x12")
+	if string(rendered) != string(expected) {
+		t.Errorf("rendered 835 does not match the sample\n%s", rendered)
+	}
+}
+
+func TestRoundTrip835(t *testing.T) {
+
+	var c Claim
+	var p Payment
+	read_json(t, "claim_paid.json", &c)
+	read_json(t, "payment_paid.json", &p)
+
+	expected, err := NewRemittance(c, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remittances, err := Parse835(read_sample(t, "835_paid.x12"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remittances) != 1 || !reflect.DeepEqual(remittances[0], expected) {
+		t.Errorf("parsed remittance does not match the claim\n%+v\n%+v", remittances, expected)
+	}
+
+	rc := expected.Claims[0]
+	var adjusted amount.Amount
+	for _, a := range rc.Adjustments {
+		adjusted += a.Amount
+	}
+	for _, l := range rc.Lines {
+		for _, a := range l.Adjustments {
+			adjusted += a.Amount
+		}
+	}
+	if rc.ChargedAmount-adjusted != rc.PaidAmount {
+		t.Errorf("remittance does not balance: charged %s, adjusted %s, paid %s", rc.ChargedAmount, adjusted, rc.PaidAmount)
+	}
+}
+
+func TestRender835Unpaid(t *testing.T) {
+
+	var c Claim
+	var p Payment
+	read_json(t, "claim_paid.json", &c)
+	read_json(t, "payment_paid.json", &p)
+
+	unpaid := c
+	unpaid.ClaimStatus = "HOSTAPPROVED"
+	if _, err := Render835(testEnvelope, unpaid, p); err == nil {
+		t.Error("expected a claim without a completed payment to be rejected")
+	}
+	short := p
+	short.Amount -= 100
+	if _, err := Render835(testEnvelope, c, short); err == nil {
+		t.Error("expected a payment that does not match the final amount to be rejected")
+	}
+}